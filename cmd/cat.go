@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/steinarvk/orc"
+
+	"github.com/steinarvk/dedu/lib/unchunker"
+	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
+)
+
+func init() {
+	var flagOffset int64
+	var flagLength int64
+	var flagCacheSize int
+
+	catCmd := orc.Command(Root, orc.Modules(orcdedu.M), cobra.Command{
+		Use:   "cat CHUNK_ID",
+		Short: "Write all or part of a chunk to stdout, downloading only what is needed",
+	}, func(chunkIds []string) error {
+		ctx := context.Background()
+
+		if len(chunkIds) != 1 {
+			return fmt.Errorf("got %d chunk IDs (%v), expected exactly 1", len(chunkIds), chunkIds)
+		}
+		chunkId := chunkIds[0]
+
+		dedu := orcdedu.M.Dedu
+
+		conn, err := dedu.OpenStorage(ctx)
+		if err != nil {
+			return err
+		}
+
+		u := &unchunker.Unchunker{
+			Storage: conn,
+			Packer:  dedu.Packer,
+			Hasher:  dedu.Hasher,
+		}
+
+		r, err := u.Open(ctx, chunkId, flagCacheSize)
+		if err != nil {
+			return err
+		}
+
+		if flagOffset < 0 || flagOffset > r.Size() {
+			return fmt.Errorf("offset %d out of range for %q of length %d", flagOffset, chunkId, r.Size())
+		}
+		length := r.Size() - flagOffset
+		if flagLength >= 0 && flagLength < length {
+			length = flagLength
+		}
+
+		out := bufio.NewWriter(os.Stdout)
+		if _, err := io.Copy(out, io.NewSectionReader(r, flagOffset, length)); err != nil {
+			return err
+		}
+		return out.Flush()
+	})
+
+	catCmd.Flags().Int64Var(&flagOffset, "offset", 0, "byte offset to start at")
+	catCmd.Flags().Int64Var(&flagLength, "length", -1, "number of bytes to write (-1 for everything until the end)")
+	catCmd.Flags().IntVar(&flagCacheSize, "cache_size", unchunker.DefaultCacheSize, "number of decrypted subchunks to keep in memory")
+}
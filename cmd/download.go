@@ -1,88 +1,81 @@
 package cmd
 
 import (
-	"bytes"
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/steinarvk/dedu/lib/pcloud"
 	"github.com/steinarvk/orc"
 
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/plainhashes"
+	"github.com/steinarvk/dedu/lib/unchunker"
 	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
 )
 
-var downloadCmd = orc.Command(debugCmd, orc.Modules(orcdedu.M), cobra.Command{
-	Use:   "download",
-	Short: "Download a chunk and write it to stdout",
-}, func(chunkIds []string) error {
-	ctx := context.Background()
+func init() {
+	var flagOutputDir string
 
-	dedu := orcdedu.M.Dedu
+	downloadCmd := orc.Command(debugCmd, orc.Modules(orcdedu.M), cobra.Command{
+		Use:   "download",
+		Short: "Download a chunk and write it to stdout",
+		Long: `Download a chunk and write it to stdout.
 
-	storage, err := pcloud.New(ctx, dedu.PcloudCreds, dedu.Config.PcloudTargetFolder)
-	if err != nil {
-		return err
-	}
+With --output_dir, each chunk is instead saved in the given directory,
+under the filename and with the modification time and permissions
+recorded when it was uploaded. A chunk without a recorded filename is
+named after its chunk ID, and one without recorded permissions gets the
+default ones. Existing files are not overwritten.
 
-	conn := storage.Connection(ctx)
+The SHA-256, SHA-1 and MD5 hashes of what was written are printed to
+stderr, in the format understood by "sha256sum -c" and similar tools.`,
+	}, func(chunkIds []string) error {
+		ctx := context.Background()
 
-	for _, chunkId := range chunkIds {
-		packed, err := conn.Get(ctx, chunkId)
-		if err != nil {
-			return err
-		}
+		dedu := orcdedu.M.Dedu
 
-		plaintext, headers, err := dedu.Packer.Unpack(packed)
+		conn, err := dedu.OpenStorage(ctx)
 		if err != nil {
 			return err
 		}
 
-		logrus.Infof("Read chunk %q with headers: %v", chunkId, headers)
-
-		if headers.Private.VirtualChunk == nil {
-			os.Stdout.Write(plaintext)
-			continue
+		u := &unchunker.Unchunker{
+			Storage: conn,
+			Packer:  dedu.Packer,
+			Hasher:  dedu.Hasher,
 		}
 
-		var content []byte
+		out := bufio.NewWriter(os.Stdout)
+		defer out.Flush()
 
-		for _, subchunk := range headers.Private.VirtualChunk.Chunk {
-			subchunkId := subchunk.Hash
+		for _, chunkId := range chunkIds {
+			hashes := plainhashes.NewWriter()
+			name := chunkId
 
-			packed, err := conn.Get(ctx, subchunkId)
-			if err != nil {
-				return err
+			if flagOutputDir != "" {
+				name, err = u.DownloadToDir(ctx, chunkId, flagOutputDir, hashes)
+			} else {
+				_, err = u.WriteChunk(ctx, chunkId, io.MultiWriter(out, hashes))
 			}
-
-			plaintext, headers, err := dedu.Packer.Unpack(packed)
 			if err != nil {
+				var chunkErr *deduchunk.Error
+				if errors.As(err, &chunkErr) && chunkErr.Check() != nil {
+					return fmt.Errorf("Download of %q failed verification of chunk %q: %v", chunkId, chunkErr.ChunkId, chunkErr.Err)
+				}
 				return err
 			}
 
-			logrus.Infof("Read subchunk %q with headers: %v", subchunkId, headers)
-
-			if headers.Private.VirtualChunk != nil {
-				return fmt.Errorf("Subchunk cannot be virtual")
+			for _, line := range plainhashes.Lines(hashes.Hashes(), name) {
+				fmt.Fprintln(os.Stderr, line)
 			}
-
-			content = append(content, plaintext...)
 		}
 
-		computedHash, err := dedu.Hasher.ComputeHash(bytes.NewReader(content))
-		if err != nil {
-			return err
-		}
-
-		logrus.Infof("Reconstructed content has hash %q (wanted %q) and length %d (wanted %d)", computedHash, chunkId, len(content), headers.Private.VirtualChunk.TotalLength)
-
-		if computedHash != chunkId {
-			return fmt.Errorf("Failed to reach expected chunkId (%q vs %q)", chunkId, computedHash)
-		}
+		return out.Flush()
+	})
 
-		os.Stdout.Write(content)
-	}
-	return nil
-})
+	downloadCmd.Flags().StringVar(&flagOutputDir, "output_dir", "", "save chunks as files in this directory, instead of writing to stdout")
+}
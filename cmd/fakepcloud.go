@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/steinarvk/orc"
+
+	"github.com/steinarvk/dedu/lib/pcloud/pcloudfake"
+)
+
+func init() {
+	var flagListen string
+	var flagUsername string
+	var flagPassword string
+	var flagFolders []string
+
+	fakePcloudCmd := orc.Command(debugCmd, orc.Modules(), cobra.Command{
+		Use:   "fake-pcloud",
+		Short: "Serve an in-memory fake of the pcloud API, for testing",
+	}, func() error {
+		l, err := net.Listen("tcp", flagListen)
+		if err != nil {
+			return err
+		}
+
+		server := pcloudfake.NewWithListener(l, flagUsername, flagPassword)
+		defer server.Close()
+
+		for _, folder := range flagFolders {
+			server.CreateFolder(folder)
+		}
+
+		fmt.Printf("api_base_url: %q\n", server.URL)
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+		sig := <-sigCh
+
+		logrus.Infof("Got %v; shutting down fake pcloud server", sig)
+
+		return nil
+	})
+
+	fakePcloudCmd.Flags().StringVar(&flagListen, "listen", "127.0.0.1:0", "address to listen on")
+	fakePcloudCmd.Flags().StringVar(&flagUsername, "username", "fake@example.com", "username to accept")
+	fakePcloudCmd.Flags().StringVar(&flagPassword, "password", "fake", "password to accept")
+	fakePcloudCmd.Flags().StringSliceVar(&flagFolders, "folder", nil, "folders to create on startup")
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/steinarvk/dedu/lib/deduhash"
 	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
 	"github.com/steinarvk/orc"
 )
@@ -27,6 +28,24 @@ func getLinesFromReader(r io.Reader) ([]string, error) {
 	return rv, nil
 }
 
+// fileMatchesHash returns whether the named file has the given deduhash,
+// which may be of any version, not only the one configured. Errors from
+// VerifyHash, including deduhash.Mismatch, are returned as they are.
+func fileMatchesHash(hasher *deduhash.Hasher, filename, hash string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	return hasher.VerifyHash(f, info.Size(), hash)
+}
+
 func getLinesFromFile(filename string) ([]string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -74,7 +93,7 @@ func init() {
 		}
 
 		tryPath := func(path string) (bool, error) {
-			info, err := os.Stat(path)
+			_, err := os.Stat(path)
 			if os.IsNotExist(err) {
 				return false, nil
 			}
@@ -90,17 +109,8 @@ func init() {
 			}
 
 			if flagHash != "" {
-				f, err := os.Open(path)
-				if err != nil {
-					return false, err
-				}
-				defer f.Close()
-
-				ok, err := dedu.Hasher.VerifyHash(f, info.Size(), flagHash)
-				if err != nil {
-					return false, err
-				}
-				if !ok {
+				ok, err := fileMatchesHash(dedu.Hasher, path, flagHash)
+				if err != nil || !ok {
 					return false, err
 				}
 			}
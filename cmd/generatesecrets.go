@@ -67,7 +67,7 @@ func init() {
 		}
 
 		if err := f.Close(); err != nil {
-			return fmt.Errorf("Error writing %q: %v", err)
+			return fmt.Errorf("Error writing %q: %v", secretsConfigFile, err)
 		}
 
 		logrus.Infof("Wrote secrets to %q (%d bytes)", secretsConfigFile, len(data))
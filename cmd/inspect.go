@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/steinarvk/orc"
+
+	"github.com/steinarvk/dedu/lib/blobstore"
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+type inspectJSON struct {
+	PackedSize int64           `json:"packed_size"`
+	HeaderSize int64           `json:"header_size"`
+	Header     json.RawMessage `json:"header"`
+}
+
+func printHashesText(indent string, hashes *pb.Hashes) {
+	if hashes == nil {
+		return
+	}
+	for _, h := range []struct {
+		name   string
+		digest []byte
+	}{
+		{"SHA-256:", hashes.Sha256},
+		{"SHA-1:", hashes.Sha1},
+		{"MD5:", hashes.Md5},
+	} {
+		if len(h.digest) > 0 {
+			fmt.Printf("%s%-*s%s\n", indent, 19-len(indent), h.name, hex.EncodeToString(h.digest))
+		}
+	}
+}
+
+func printHeaderText(packedSize int64, header *pb.Header) {
+	private := header.Private
+
+	fmt.Printf("Protocol version:  %d\n", header.Magic.ProtocolVersion)
+	fmt.Printf("Chunk ID:          %s\n", header.Public.ChunkId)
+	fmt.Printf("Packed size:       %d\n", packedSize)
+	fmt.Printf("Header size:       %d (private header %d)\n", deduchunk.HeaderSize(header), header.Public.PrivateHeaderLength)
+	fmt.Printf("Plaintext length:  %d\n", private.PlaintextLength)
+	fmt.Printf("Compression:       %v\n", private.Compression)
+	fmt.Printf("Padding:           %v\n", private.Padding)
+	printHashesText("", private.PlaintextHashes)
+	if md := private.OptionalMetadata; md != nil {
+		fmt.Printf("Metadata:          %s\n", proto.CompactTextString(md))
+	}
+	if vc := private.VirtualChunk; vc != nil {
+		fmt.Printf("Virtual chunk:     %s (%d bytes in %d subchunks)\n", vc.ChunkId, vc.TotalLength, len(vc.Chunk))
+		fmt.Printf("  Depth:           %d\n", deduchunk.ManifestDepth(vc))
+		if vc.Intermediate {
+			fmt.Printf("  Intermediate:    true\n")
+		}
+		printHashesText("  ", vc.PlaintextHashes)
+		for i, ref := range vc.Chunk {
+			var suffix string
+			if ref.Virtual {
+				suffix = "  [virtual]"
+			}
+			fmt.Printf("  %6d  %s  %d%s\n", i, ref.Hash, ref.Length, suffix)
+		}
+	}
+}
+
+func printHeaderJSON(packedSize int64, header *pb.Header) error {
+	m := jsonpb.Marshaler{OrigName: true}
+	headerJSON, err := m.MarshalToString(header)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(inspectJSON{
+		PackedSize: packedSize,
+		HeaderSize: deduchunk.HeaderSize(header),
+		Header:     json.RawMessage(headerJSON),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func init() {
+	var flagFormat string
+
+	inspectCmd := orc.Command(debugCmd, orc.Modules(orcdedu.M), cobra.Command{
+		Use:   "inspect CHUNK_ID|FILE",
+		Short: "Show the headers of a packed chunk, without decrypting its data",
+		Long: `Show the headers of a packed chunk, without decrypting its data.
+
+The argument is read as a file containing a packed chunk if it exists,
+and as the ID of a chunk in remote storage otherwise. Only the headers
+are read. The chunk-specific encryption key is never shown.`,
+	}, func(args []string) error {
+		ctx := context.Background()
+
+		if len(args) != 1 {
+			return fmt.Errorf("got %d arguments (%v), expected exactly 1", len(args), args)
+		}
+		name := args[0]
+
+		if flagFormat != "text" && flagFormat != "json" {
+			return fmt.Errorf("invalid --format %q (want text or json)", flagFormat)
+		}
+
+		dedu := orcdedu.M.Dedu
+
+		var r io.ReadCloser
+		var packedSize int64
+		var chunkId string
+
+		if info, err := os.Stat(name); err == nil {
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			r = f
+			packedSize = info.Size()
+		} else {
+			chunkId = name
+
+			conn, err := dedu.OpenStorage(ctx)
+			if err != nil {
+				return err
+			}
+
+			info, err := conn.Stat(ctx, chunkId)
+			if err != nil {
+				return err
+			}
+			packedSize = info.Size
+
+			r, err = blobstore.GetReader(ctx, conn, chunkId)
+			if err != nil {
+				return err
+			}
+		}
+		defer r.Close()
+
+		header, err := dedu.Packer.InspectHeaderFrom(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		header.Private.ChunkSpecificEncryptionKey = nil
+
+		if chunkId != "" && header.Public.ChunkId != chunkId {
+			logrus.Warnf("Chunk stored as %q has chunk ID %q in header", chunkId, header.Public.ChunkId)
+		}
+
+		if flagFormat == "json" {
+			return printHeaderJSON(packedSize, header)
+		}
+		printHeaderText(packedSize, header)
+		return nil
+	})
+
+	inspectCmd.Flags().StringVar(&flagFormat, "format", "text", "output format (text, json)")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/steinarvk/orc"
+
+	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
+)
+
+func init() {
+	var flagStartAfter string
+	var flagLimit int
+	var flagNamesOnly bool
+
+	lsRemoteCmd := orc.Command(debugCmd, orc.Modules(orcdedu.M), cobra.Command{
+		Use:   "ls-remote [PREFIX]",
+		Short: "List chunks in remote storage",
+		Long: `List chunks in remote storage.
+
+The complete listing (of chunks matching PREFIX) is always fetched from
+storage; --start_after and --limit only select which part of it is printed.`,
+	}, func(prefixes []string) error {
+		ctx := context.Background()
+
+		if len(prefixes) > 1 {
+			return fmt.Errorf("got %d prefixes (%v), expected at most 1", len(prefixes), prefixes)
+		}
+
+		var prefix string
+		if len(prefixes) == 1 {
+			prefix = prefixes[0]
+		}
+
+		dedu := orcdedu.M.Dedu
+
+		conn, err := dedu.OpenStorage(ctx)
+		if err != nil {
+			return err
+		}
+
+		infos, err := conn.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Name < infos[j].Name
+		})
+
+		shown := 0
+		for _, info := range infos {
+			if info.Name <= flagStartAfter {
+				continue
+			}
+			if flagLimit > 0 && shown >= flagLimit {
+				break
+			}
+			shown++
+
+			if flagNamesOnly {
+				fmt.Println(info.Name)
+			} else {
+				fmt.Printf("%s\t%d\t%s\n", info.Name, info.Size, info.Modified.UTC().Format(time.RFC3339))
+			}
+		}
+
+		return nil
+	})
+
+	lsRemoteCmd.Flags().StringVar(&flagStartAfter, "start_after", "", "only print chunks whose names sort after this one (filtered client-side; the full listing is still fetched)")
+	lsRemoteCmd.Flags().IntVar(&flagLimit, "limit", 0, "maximum number of chunks to print (0 for no limit; applied client-side)")
+	lsRemoteCmd.Flags().BoolVar(&flagNamesOnly, "names_only", false, "only print chunk names, not size and modification time")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/steinarvk/orc"
+
+	"github.com/steinarvk/dedu/lib/pcloud"
+	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
+)
+
+var pcloudTokenCmd = orc.Command(debugCmd, orc.Modules(orcdedu.M), cobra.Command{
+	Use:   "pcloud-token",
+	Short: "Log in to pcloud and print an auth token to use instead of the password",
+}, func() error {
+	ctx := context.Background()
+
+	dedu := orcdedu.M.Dedu
+
+	if dedu.PcloudCreds == nil {
+		return fmt.Errorf("no storage_creds.pcloud configured")
+	}
+
+	httpClient, err := dedu.HTTPClient()
+	if err != nil {
+		return err
+	}
+
+	storage, err := pcloud.New(ctx, dedu.PcloudCreds, dedu.Config.PcloudTargetFolder, httpClient)
+	if err != nil {
+		return err
+	}
+
+	token, err := storage.AuthToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+
+	return nil
+})
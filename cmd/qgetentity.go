@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -111,6 +112,18 @@ func (o registerOrGetOpts) registerOrGetEntity(filename string) (string, error)
 				continue
 			}
 
+			// An entity identified by a hash of another version (e.g. from
+			// before a change of hash_version) may still be correct.
+			if version, err := deduhash.VersionOf(matchingEntity); err == nil && version != o.dedu.Hasher.Version() {
+				ok, err := fileMatchesHash(o.dedu.Hasher, filename, matchingEntity)
+				if err != nil && !errors.Is(err, deduhash.Mismatch) {
+					return dh, err
+				}
+				if ok {
+					continue
+				}
+			}
+
 			// No longer matches.
 			pathsFilePath := deduq.Filename(matchingEntity, "paths")
 			if err := lines.RemoveFromFile(pathsFilePath, []string{filename}, true); err != nil {
@@ -53,7 +53,7 @@ func init() {
 			}
 
 			tryPath := func(path string) (bool, error) {
-				info, err := os.Stat(path)
+				_, err := os.Stat(path)
 				if os.IsNotExist(err) {
 					return false, nil
 				}
@@ -67,17 +67,8 @@ func init() {
 				}
 
 				if flagVerify {
-					f, err := os.Open(path)
-					if err != nil {
-						return false, err
-					}
-					defer f.Close()
-
-					ok, err := dedu.Hasher.VerifyHash(f, info.Size(), entityID)
-					if err != nil {
-						return false, err
-					}
-					if !ok {
+					ok, err := fileMatchesHash(dedu.Hasher, path, entityID)
+					if err != nil || !ok {
 						return false, err
 					}
 				}
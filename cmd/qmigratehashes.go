@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/steinarvk/linetool/lib/lines"
+	"github.com/steinarvk/orc"
+
+	"github.com/steinarvk/dedu/lib/deduhash"
+	orcdedu "github.com/steinarvk/dedu/module/orc-dedu"
+	orcdeduq "github.com/steinarvk/dedu/module/orc-deduq"
+)
+
+// migrateEntityHash finds a path of the given version 1 entity whose
+// contents still match it, and returns the version 2 hash of that path.
+func migrateEntityHash(hasherV2 *deduhash.Hasher, entityID string) (string, string, error) {
+	paths, err := orcdeduq.M.FileLines(entityID, "paths")
+	if err != nil {
+		return "", "", err
+	}
+
+	tryPath := func(path string) (string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		v1, err := hasherV2.NewWriterFor(entityID)
+		if err != nil {
+			return "", err
+		}
+		v2 := hasherV2.NewWriter()
+
+		if _, err := io.Copy(io.MultiWriter(v1, v2), f); err != nil {
+			return "", err
+		}
+
+		got, err := v1.Sum()
+		if err != nil {
+			return "", err
+		}
+		if got != entityID {
+			return "", fmt.Errorf("%q no longer matches (hashes to %q)", path, got)
+		}
+		return v2.Sum()
+	}
+
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		newID, err := tryPath(path)
+		if err != nil {
+			logrus.Warnf("Unable to use %q for %q: %v", path, entityID, err)
+			continue
+		}
+		return newID, path, nil
+	}
+
+	return "", "", fmt.Errorf("no suitable path found for %q (tried %v)", entityID, paths)
+}
+
+func init() {
+	var flagDryRun bool
+
+	var qMigrateHashesCmd = orc.Command(qCmd, orc.Modules(orcdedu.M, orcdeduq.M), cobra.Command{
+		Use:   "migrate-hashes [ENTITY_ID...]",
+		Short: "Map entities with version 1 hashes to their version 2 hashes",
+		Long: `Map entities with version 1 hashes to their version 2 hashes.
+
+Each entity (read from stdin if none are given) is rehashed from the
+first of its paths that still matches its ID. The version 2 entity gets
+the path and quasihash, and the two entities are linked through their
+"deduhash-v2" and "deduhash-v1" files. The mapping is printed as
+tab-separated lines.`,
+	}, func(entityIDs []string) error {
+		deduq := orcdeduq.M
+
+		hasherV2, err := orcdedu.M.Dedu.Hasher.WithVersion(deduhash.Version2)
+		if err != nil {
+			return err
+		}
+
+		if len(entityIDs) == 0 {
+			logrus.Infof("Reading entity IDs from stdin")
+			entityIDs, err = lines.Read(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("error reading entity IDs from stdin: %v", err)
+			}
+		}
+
+		var failed int
+		for _, entityID := range entityIDs {
+			if !deduhash.LooksLikeDeduhash(entityID) {
+				return fmt.Errorf("argument %q does not appear to be a hash", entityID)
+			}
+			if entityID[:2] != deduhash.Version1+"-" {
+				logrus.Infof("Skipping %q: not a version 1 hash", entityID)
+				continue
+			}
+
+			if existing, err := deduq.FileLines(entityID, "deduhash-v2"); err == nil && len(existing) == 1 {
+				fmt.Printf("%s\t%s\n", entityID, existing[0])
+				continue
+			}
+
+			newID, path, err := migrateEntityHash(hasherV2, entityID)
+			if err != nil {
+				logrus.Errorf("Unable to migrate %q: %v", entityID, err)
+				failed++
+				continue
+			}
+
+			if !flagDryRun {
+				if qh, err := deduq.FileLines(entityID, "quasihash"); err == nil {
+					if err := lines.CreateOrExpect(deduq.Filename(newID, "quasihash"), qh, true); err != nil {
+						return err
+					}
+				}
+				if err := lines.AddNewToFile(deduq.Filename(newID, "paths"), []string{path}); err != nil {
+					return err
+				}
+				if err := lines.CreateOrExpect(deduq.Filename(newID, "deduhash-v1"), []string{entityID}, true); err != nil {
+					return err
+				}
+				if err := lines.CreateOrExpect(deduq.Filename(entityID, "deduhash-v2"), []string{newID}, true); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("%s\t%s\n", entityID, newID)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to migrate %d of %d entities", failed, len(entityIDs))
+		}
+		return nil
+	})
+
+	qMigrateHashesCmd.Flags().BoolVar(&flagDryRun, "dry_run", false, "only print the mapping; don't modify any entities")
+}
@@ -20,12 +20,68 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type Compression int32
+
+const (
+	Compression_COMPRESSION_NONE    Compression = 0
+	Compression_COMPRESSION_DEFLATE Compression = 1
+)
+
+var Compression_name = map[int32]string{
+	0: "COMPRESSION_NONE",
+	1: "COMPRESSION_DEFLATE",
+}
+
+var Compression_value = map[string]int32{
+	"COMPRESSION_NONE":    0,
+	"COMPRESSION_DEFLATE": 1,
+}
+
+func (x Compression) String() string {
+	return proto.EnumName(Compression_name, int32(x))
+}
+
+func (Compression) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{0}
+}
+
+type Padding int32
+
+const (
+	Padding_PADDING_NONE         Padding = 0
+	Padding_PADDING_PADME        Padding = 1
+	Padding_PADDING_POWER_OF_TWO Padding = 2
+)
+
+var Padding_name = map[int32]string{
+	0: "PADDING_NONE",
+	1: "PADDING_PADME",
+	2: "PADDING_POWER_OF_TWO",
+}
+
+var Padding_value = map[string]int32{
+	"PADDING_NONE":         0,
+	"PADDING_PADME":        1,
+	"PADDING_POWER_OF_TWO": 2,
+}
+
+func (x Padding) String() string {
+	return proto.EnumName(Padding_name, int32(x))
+}
+
+func (Padding) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{1}
+}
+
 type ChunkMetadata struct {
-	UploadTimestamp      string   `protobuf:"bytes,1,opt,name=upload_timestamp,json=uploadTimestamp,proto3" json:"upload_timestamp,omitempty"`
-	SuggestedFilename    string   `protobuf:"bytes,2,opt,name=suggested_filename,json=suggestedFilename,proto3" json:"suggested_filename,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	UploadTimestamp       string   `protobuf:"bytes,1,opt,name=upload_timestamp,json=uploadTimestamp,proto3" json:"upload_timestamp,omitempty"`
+	SuggestedFilename     string   `protobuf:"bytes,2,opt,name=suggested_filename,json=suggestedFilename,proto3" json:"suggested_filename,omitempty"`
+	ModificationTimestamp string   `protobuf:"bytes,3,opt,name=modification_timestamp,json=modificationTimestamp,proto3" json:"modification_timestamp,omitempty"`
+	Mode                  uint32   `protobuf:"varint,4,opt,name=mode,proto3" json:"mode,omitempty"`
+	Hostname              string   `protobuf:"bytes,5,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{} `json:"-"`
+	XXX_unrecognized      []byte   `json:"-"`
+	XXX_sizecache         int32    `json:"-"`
 }
 
 func (m *ChunkMetadata) Reset()         { *m = ChunkMetadata{} }
@@ -67,6 +123,27 @@ func (m *ChunkMetadata) GetSuggestedFilename() string {
 	return ""
 }
 
+func (m *ChunkMetadata) GetModificationTimestamp() string {
+	if m != nil {
+		return m.ModificationTimestamp
+	}
+	return ""
+}
+
+func (m *ChunkMetadata) GetMode() uint32 {
+	if m != nil {
+		return m.Mode
+	}
+	return 0
+}
+
+func (m *ChunkMetadata) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
+}
+
 type MagicHeader struct {
 	Dedu                 string   `protobuf:"bytes,1,opt,name=dedu,proto3" json:"dedu,omitempty"`
 	ProtocolVersion      int32    `protobuf:"varint,2,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
@@ -172,6 +249,7 @@ func (m *PublicHeader) GetPrivateHeaderLength() int32 {
 type Hashes struct {
 	Sha1                 []byte   `protobuf:"bytes,1,opt,name=sha1,proto3" json:"sha1,omitempty"`
 	Md5                  []byte   `protobuf:"bytes,2,opt,name=md5,proto3" json:"md5,omitempty"`
+	Sha256               []byte   `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -216,12 +294,21 @@ func (m *Hashes) GetMd5() []byte {
 	return nil
 }
 
+func (m *Hashes) GetSha256() []byte {
+	if m != nil {
+		return m.Sha256
+	}
+	return nil
+}
+
 type PrivateHeader struct {
 	VirtualChunk               *VirtualChunk  `protobuf:"bytes,1,opt,name=virtual_chunk,json=virtualChunk,proto3" json:"virtual_chunk,omitempty"`
 	ChunkSpecificEncryptionKey []byte         `protobuf:"bytes,2,opt,name=chunk_specific_encryption_key,json=chunkSpecificEncryptionKey,proto3" json:"chunk_specific_encryption_key,omitempty"`
 	OptionalMetadata           *ChunkMetadata `protobuf:"bytes,3,opt,name=optional_metadata,json=optionalMetadata,proto3" json:"optional_metadata,omitempty"`
 	PlaintextHashes            *Hashes        `protobuf:"bytes,4,opt,name=plaintext_hashes,json=plaintextHashes,proto3" json:"plaintext_hashes,omitempty"`
-	PlaintextLength            int32          `protobuf:"varint,5,opt,name=plaintext_length,json=plaintextLength,proto3" json:"plaintext_length,omitempty"`
+	PlaintextLength            int64          `protobuf:"varint,5,opt,name=plaintext_length,json=plaintextLength,proto3" json:"plaintext_length,omitempty"`
+	Compression                Compression    `protobuf:"varint,6,opt,name=compression,proto3,enum=dedupb.Compression" json:"compression,omitempty"`
+	Padding                    Padding        `protobuf:"varint,7,opt,name=padding,proto3,enum=dedupb.Padding" json:"padding,omitempty"`
 	XXX_NoUnkeyedLiteral       struct{}       `json:"-"`
 	XXX_unrecognized           []byte         `json:"-"`
 	XXX_sizecache              int32          `json:"-"`
@@ -280,13 +367,27 @@ func (m *PrivateHeader) GetPlaintextHashes() *Hashes {
 	return nil
 }
 
-func (m *PrivateHeader) GetPlaintextLength() int32 {
+func (m *PrivateHeader) GetPlaintextLength() int64 {
 	if m != nil {
 		return m.PlaintextLength
 	}
 	return 0
 }
 
+func (m *PrivateHeader) GetCompression() Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return Compression_COMPRESSION_NONE
+}
+
+func (m *PrivateHeader) GetPadding() Padding {
+	if m != nil {
+		return m.Padding
+	}
+	return Padding_PADDING_NONE
+}
+
 type Header struct {
 	Magic                *MagicHeader   `protobuf:"bytes,1,opt,name=magic,proto3" json:"magic,omitempty"`
 	Public               *PublicHeader  `protobuf:"bytes,2,opt,name=public,proto3" json:"public,omitempty"`
@@ -345,6 +446,7 @@ func (m *Header) GetPrivate() *PrivateHeader {
 type ChunkReference struct {
 	Hash                 string   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
 	Length               int64    `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	Virtual              bool     `protobuf:"varint,3,opt,name=virtual,proto3" json:"virtual,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -389,11 +491,20 @@ func (m *ChunkReference) GetLength() int64 {
 	return 0
 }
 
+func (m *ChunkReference) GetVirtual() bool {
+	if m != nil {
+		return m.Virtual
+	}
+	return false
+}
+
 type VirtualChunk struct {
 	TotalLength          int64             `protobuf:"varint,1,opt,name=total_length,json=totalLength,proto3" json:"total_length,omitempty"`
 	Chunk                []*ChunkReference `protobuf:"bytes,2,rep,name=chunk,proto3" json:"chunk,omitempty"`
 	PlaintextHashes      *Hashes           `protobuf:"bytes,3,opt,name=plaintext_hashes,json=plaintextHashes,proto3" json:"plaintext_hashes,omitempty"`
 	ChunkId              string            `protobuf:"bytes,4,opt,name=chunk_id,json=chunkId,proto3" json:"chunk_id,omitempty"`
+	Depth                int32             `protobuf:"varint,5,opt,name=depth,proto3" json:"depth,omitempty"`
+	Intermediate         bool              `protobuf:"varint,6,opt,name=intermediate,proto3" json:"intermediate,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
 	XXX_unrecognized     []byte            `json:"-"`
 	XXX_sizecache        int32             `json:"-"`
@@ -452,6 +563,20 @@ func (m *VirtualChunk) GetChunkId() string {
 	return ""
 }
 
+func (m *VirtualChunk) GetDepth() int32 {
+	if m != nil {
+		return m.Depth
+	}
+	return 0
+}
+
+func (m *VirtualChunk) GetIntermediate() bool {
+	if m != nil {
+		return m.Intermediate
+	}
+	return false
+}
+
 type LocalResourceChunk struct {
 	ResourceName         string          `protobuf:"bytes,1,opt,name=resource_name,json=resourceName,proto3" json:"resource_name,omitempty"`
 	Offset               int64           `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
@@ -534,6 +659,8 @@ func (m *LocalResourceChunk) GetChunk() *ChunkReference {
 type PcloudCredentials struct {
 	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
 	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	AuthToken            string   `protobuf:"bytes,3,opt,name=auth_token,json=authToken,proto3" json:"auth_token,omitempty"`
+	ApiBaseUrl           string   `protobuf:"bytes,4,opt,name=api_base_url,json=apiBaseUrl,proto3" json:"api_base_url,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -578,18 +705,208 @@ func (m *PcloudCredentials) GetPassword() string {
 	return ""
 }
 
+func (m *PcloudCredentials) GetAuthToken() string {
+	if m != nil {
+		return m.AuthToken
+	}
+	return ""
+}
+
+func (m *PcloudCredentials) GetApiBaseUrl() string {
+	if m != nil {
+		return m.ApiBaseUrl
+	}
+	return ""
+}
+
+type LocalStorageCredentials struct {
+	RootDirectory        string   `protobuf:"bytes,1,opt,name=root_directory,json=rootDirectory,proto3" json:"root_directory,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LocalStorageCredentials) Reset()         { *m = LocalStorageCredentials{} }
+func (m *LocalStorageCredentials) String() string { return proto.CompactTextString(m) }
+func (*LocalStorageCredentials) ProtoMessage()    {}
+func (*LocalStorageCredentials) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{10}
+}
+
+func (m *LocalStorageCredentials) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LocalStorageCredentials.Unmarshal(m, b)
+}
+func (m *LocalStorageCredentials) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LocalStorageCredentials.Marshal(b, m, deterministic)
+}
+func (m *LocalStorageCredentials) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LocalStorageCredentials.Merge(m, src)
+}
+func (m *LocalStorageCredentials) XXX_Size() int {
+	return xxx_messageInfo_LocalStorageCredentials.Size(m)
+}
+func (m *LocalStorageCredentials) XXX_DiscardUnknown() {
+	xxx_messageInfo_LocalStorageCredentials.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LocalStorageCredentials proto.InternalMessageInfo
+
+func (m *LocalStorageCredentials) GetRootDirectory() string {
+	if m != nil {
+		return m.RootDirectory
+	}
+	return ""
+}
+
+type S3Credentials struct {
+	Endpoint             string   `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Region               string   `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	Bucket               string   `protobuf:"bytes,3,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	AccessKeyId          string   `protobuf:"bytes,4,opt,name=access_key_id,json=accessKeyId,proto3" json:"access_key_id,omitempty"`
+	SecretAccessKey      string   `protobuf:"bytes,5,opt,name=secret_access_key,json=secretAccessKey,proto3" json:"secret_access_key,omitempty"`
+	VirtualHostedStyle   bool     `protobuf:"varint,6,opt,name=virtual_hosted_style,json=virtualHostedStyle,proto3" json:"virtual_hosted_style,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *S3Credentials) Reset()         { *m = S3Credentials{} }
+func (m *S3Credentials) String() string { return proto.CompactTextString(m) }
+func (*S3Credentials) ProtoMessage()    {}
+func (*S3Credentials) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{11}
+}
+
+func (m *S3Credentials) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_S3Credentials.Unmarshal(m, b)
+}
+func (m *S3Credentials) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_S3Credentials.Marshal(b, m, deterministic)
+}
+func (m *S3Credentials) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_S3Credentials.Merge(m, src)
+}
+func (m *S3Credentials) XXX_Size() int {
+	return xxx_messageInfo_S3Credentials.Size(m)
+}
+func (m *S3Credentials) XXX_DiscardUnknown() {
+	xxx_messageInfo_S3Credentials.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_S3Credentials proto.InternalMessageInfo
+
+func (m *S3Credentials) GetEndpoint() string {
+	if m != nil {
+		return m.Endpoint
+	}
+	return ""
+}
+
+func (m *S3Credentials) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+func (m *S3Credentials) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *S3Credentials) GetAccessKeyId() string {
+	if m != nil {
+		return m.AccessKeyId
+	}
+	return ""
+}
+
+func (m *S3Credentials) GetSecretAccessKey() string {
+	if m != nil {
+		return m.SecretAccessKey
+	}
+	return ""
+}
+
+func (m *S3Credentials) GetVirtualHostedStyle() bool {
+	if m != nil {
+		return m.VirtualHostedStyle
+	}
+	return false
+}
+
+type WebdavCredentials struct {
+	BaseUrl              string   `protobuf:"bytes,1,opt,name=base_url,json=baseUrl,proto3" json:"base_url,omitempty"`
+	Username             string   `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WebdavCredentials) Reset()         { *m = WebdavCredentials{} }
+func (m *WebdavCredentials) String() string { return proto.CompactTextString(m) }
+func (*WebdavCredentials) ProtoMessage()    {}
+func (*WebdavCredentials) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{12}
+}
+
+func (m *WebdavCredentials) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WebdavCredentials.Unmarshal(m, b)
+}
+func (m *WebdavCredentials) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WebdavCredentials.Marshal(b, m, deterministic)
+}
+func (m *WebdavCredentials) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WebdavCredentials.Merge(m, src)
+}
+func (m *WebdavCredentials) XXX_Size() int {
+	return xxx_messageInfo_WebdavCredentials.Size(m)
+}
+func (m *WebdavCredentials) XXX_DiscardUnknown() {
+	xxx_messageInfo_WebdavCredentials.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WebdavCredentials proto.InternalMessageInfo
+
+func (m *WebdavCredentials) GetBaseUrl() string {
+	if m != nil {
+		return m.BaseUrl
+	}
+	return ""
+}
+
+func (m *WebdavCredentials) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *WebdavCredentials) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
 type StorageCredentials struct {
-	Pcloud               *PcloudCredentials `protobuf:"bytes,1,opt,name=pcloud,proto3" json:"pcloud,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
-	XXX_unrecognized     []byte             `json:"-"`
-	XXX_sizecache        int32              `json:"-"`
+	Pcloud               *PcloudCredentials       `protobuf:"bytes,1,opt,name=pcloud,proto3" json:"pcloud,omitempty"`
+	Local                *LocalStorageCredentials `protobuf:"bytes,2,opt,name=local,proto3" json:"local,omitempty"`
+	S3                   *S3Credentials           `protobuf:"bytes,3,opt,name=s3,proto3" json:"s3,omitempty"`
+	Webdav               *WebdavCredentials       `protobuf:"bytes,4,opt,name=webdav,proto3" json:"webdav,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                 `json:"-"`
+	XXX_unrecognized     []byte                   `json:"-"`
+	XXX_sizecache        int32                    `json:"-"`
 }
 
 func (m *StorageCredentials) Reset()         { *m = StorageCredentials{} }
 func (m *StorageCredentials) String() string { return proto.CompactTextString(m) }
 func (*StorageCredentials) ProtoMessage()    {}
 func (*StorageCredentials) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a41550a7431a5bcb, []int{10}
+	return fileDescriptor_a41550a7431a5bcb, []int{13}
 }
 
 func (m *StorageCredentials) XXX_Unmarshal(b []byte) error {
@@ -617,6 +934,27 @@ func (m *StorageCredentials) GetPcloud() *PcloudCredentials {
 	return nil
 }
 
+func (m *StorageCredentials) GetLocal() *LocalStorageCredentials {
+	if m != nil {
+		return m.Local
+	}
+	return nil
+}
+
+func (m *StorageCredentials) GetS3() *S3Credentials {
+	if m != nil {
+		return m.S3
+	}
+	return nil
+}
+
+func (m *StorageCredentials) GetWebdav() *WebdavCredentials {
+	if m != nil {
+		return m.Webdav
+	}
+	return nil
+}
+
 type Keyset struct {
 	// Types that are valid to be assigned to Kind:
 	//	*Keyset_UnencryptedTinkKeyset
@@ -630,7 +968,7 @@ func (m *Keyset) Reset()         { *m = Keyset{} }
 func (m *Keyset) String() string { return proto.CompactTextString(m) }
 func (*Keyset) ProtoMessage()    {}
 func (*Keyset) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a41550a7431a5bcb, []int{11}
+	return fileDescriptor_a41550a7431a5bcb, []int{14}
 }
 
 func (m *Keyset) XXX_Unmarshal(b []byte) error {
@@ -737,7 +1075,7 @@ func (m *QmfsConfig) Reset()         { *m = QmfsConfig{} }
 func (m *QmfsConfig) String() string { return proto.CompactTextString(m) }
 func (*QmfsConfig) ProtoMessage()    {}
 func (*QmfsConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a41550a7431a5bcb, []int{12}
+	return fileDescriptor_a41550a7431a5bcb, []int{15}
 }
 
 func (m *QmfsConfig) XXX_Unmarshal(b []byte) error {
@@ -765,21 +1103,252 @@ func (m *QmfsConfig) GetQmfsRoot() string {
 	return ""
 }
 
+type HttpClientConfig struct {
+	Timeout               string   `protobuf:"bytes,1,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	DialTimeout           string   `protobuf:"bytes,2,opt,name=dial_timeout,json=dialTimeout,proto3" json:"dial_timeout,omitempty"`
+	TlsHandshakeTimeout   string   `protobuf:"bytes,3,opt,name=tls_handshake_timeout,json=tlsHandshakeTimeout,proto3" json:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout string   `protobuf:"bytes,4,opt,name=response_header_timeout,json=responseHeaderTimeout,proto3" json:"response_header_timeout,omitempty"`
+	IdleConnTimeout       string   `protobuf:"bytes,5,opt,name=idle_conn_timeout,json=idleConnTimeout,proto3" json:"idle_conn_timeout,omitempty"`
+	ProxyUrl              string   `protobuf:"bytes,6,opt,name=proxy_url,json=proxyUrl,proto3" json:"proxy_url,omitempty"`
+	CaCertFile            string   `protobuf:"bytes,7,opt,name=ca_cert_file,json=caCertFile,proto3" json:"ca_cert_file,omitempty"`
+	InsecureSkipVerify    bool     `protobuf:"varint,8,opt,name=insecure_skip_verify,json=insecureSkipVerify,proto3" json:"insecure_skip_verify,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{} `json:"-"`
+	XXX_unrecognized      []byte   `json:"-"`
+	XXX_sizecache         int32    `json:"-"`
+}
+
+func (m *HttpClientConfig) Reset()         { *m = HttpClientConfig{} }
+func (m *HttpClientConfig) String() string { return proto.CompactTextString(m) }
+func (*HttpClientConfig) ProtoMessage()    {}
+func (*HttpClientConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{16}
+}
+
+func (m *HttpClientConfig) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HttpClientConfig.Unmarshal(m, b)
+}
+func (m *HttpClientConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HttpClientConfig.Marshal(b, m, deterministic)
+}
+func (m *HttpClientConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HttpClientConfig.Merge(m, src)
+}
+func (m *HttpClientConfig) XXX_Size() int {
+	return xxx_messageInfo_HttpClientConfig.Size(m)
+}
+func (m *HttpClientConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_HttpClientConfig.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HttpClientConfig proto.InternalMessageInfo
+
+func (m *HttpClientConfig) GetTimeout() string {
+	if m != nil {
+		return m.Timeout
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetDialTimeout() string {
+	if m != nil {
+		return m.DialTimeout
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetTlsHandshakeTimeout() string {
+	if m != nil {
+		return m.TlsHandshakeTimeout
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetResponseHeaderTimeout() string {
+	if m != nil {
+		return m.ResponseHeaderTimeout
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetIdleConnTimeout() string {
+	if m != nil {
+		return m.IdleConnTimeout
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetProxyUrl() string {
+	if m != nil {
+		return m.ProxyUrl
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetCaCertFile() string {
+	if m != nil {
+		return m.CaCertFile
+	}
+	return ""
+}
+
+func (m *HttpClientConfig) GetInsecureSkipVerify() bool {
+	if m != nil {
+		return m.InsecureSkipVerify
+	}
+	return false
+}
+
+type ContentDefinedChunkingConfig struct {
+	MinSize              int64    `protobuf:"varint,1,opt,name=min_size,json=minSize,proto3" json:"min_size,omitempty"`
+	AvgSize              int64    `protobuf:"varint,2,opt,name=avg_size,json=avgSize,proto3" json:"avg_size,omitempty"`
+	MaxSize              int64    `protobuf:"varint,3,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ContentDefinedChunkingConfig) Reset()         { *m = ContentDefinedChunkingConfig{} }
+func (m *ContentDefinedChunkingConfig) String() string { return proto.CompactTextString(m) }
+func (*ContentDefinedChunkingConfig) ProtoMessage()    {}
+func (*ContentDefinedChunkingConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{17}
+}
+
+func (m *ContentDefinedChunkingConfig) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ContentDefinedChunkingConfig.Unmarshal(m, b)
+}
+func (m *ContentDefinedChunkingConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ContentDefinedChunkingConfig.Marshal(b, m, deterministic)
+}
+func (m *ContentDefinedChunkingConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ContentDefinedChunkingConfig.Merge(m, src)
+}
+func (m *ContentDefinedChunkingConfig) XXX_Size() int {
+	return xxx_messageInfo_ContentDefinedChunkingConfig.Size(m)
+}
+func (m *ContentDefinedChunkingConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_ContentDefinedChunkingConfig.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ContentDefinedChunkingConfig proto.InternalMessageInfo
+
+func (m *ContentDefinedChunkingConfig) GetMinSize() int64 {
+	if m != nil {
+		return m.MinSize
+	}
+	return 0
+}
+
+func (m *ContentDefinedChunkingConfig) GetAvgSize() int64 {
+	if m != nil {
+		return m.AvgSize
+	}
+	return 0
+}
+
+func (m *ContentDefinedChunkingConfig) GetMaxSize() int64 {
+	if m != nil {
+		return m.MaxSize
+	}
+	return 0
+}
+
+type UploadConfig struct {
+	ChunkWorkers         int32    `protobuf:"varint,1,opt,name=chunk_workers,json=chunkWorkers,proto3" json:"chunk_workers,omitempty"`
+	PackWorkers          int32    `protobuf:"varint,2,opt,name=pack_workers,json=packWorkers,proto3" json:"pack_workers,omitempty"`
+	UploadWorkers        int32    `protobuf:"varint,3,opt,name=upload_workers,json=uploadWorkers,proto3" json:"upload_workers,omitempty"`
+	MaxInFlightBytes     int64    `protobuf:"varint,4,opt,name=max_in_flight_bytes,json=maxInFlightBytes,proto3" json:"max_in_flight_bytes,omitempty"`
+	MaxManifestEntries   int32    `protobuf:"varint,5,opt,name=max_manifest_entries,json=maxManifestEntries,proto3" json:"max_manifest_entries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UploadConfig) Reset()         { *m = UploadConfig{} }
+func (m *UploadConfig) String() string { return proto.CompactTextString(m) }
+func (*UploadConfig) ProtoMessage()    {}
+func (*UploadConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a41550a7431a5bcb, []int{18}
+}
+
+func (m *UploadConfig) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UploadConfig.Unmarshal(m, b)
+}
+func (m *UploadConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UploadConfig.Marshal(b, m, deterministic)
+}
+func (m *UploadConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UploadConfig.Merge(m, src)
+}
+func (m *UploadConfig) XXX_Size() int {
+	return xxx_messageInfo_UploadConfig.Size(m)
+}
+func (m *UploadConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_UploadConfig.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UploadConfig proto.InternalMessageInfo
+
+func (m *UploadConfig) GetChunkWorkers() int32 {
+	if m != nil {
+		return m.ChunkWorkers
+	}
+	return 0
+}
+
+func (m *UploadConfig) GetPackWorkers() int32 {
+	if m != nil {
+		return m.PackWorkers
+	}
+	return 0
+}
+
+func (m *UploadConfig) GetUploadWorkers() int32 {
+	if m != nil {
+		return m.UploadWorkers
+	}
+	return 0
+}
+
+func (m *UploadConfig) GetMaxInFlightBytes() int64 {
+	if m != nil {
+		return m.MaxInFlightBytes
+	}
+	return 0
+}
+
+func (m *UploadConfig) GetMaxManifestEntries() int32 {
+	if m != nil {
+		return m.MaxManifestEntries
+	}
+	return 0
+}
+
 type DeduConfig struct {
-	EmptyBlobHashSanityCheck string      `protobuf:"bytes,1,opt,name=empty_blob_hash_sanity_check,json=emptyBlobHashSanityCheck,proto3" json:"empty_blob_hash_sanity_check,omitempty"`
-	PcloudTargetFolder       string      `protobuf:"bytes,2,opt,name=pcloud_target_folder,json=pcloudTargetFolder,proto3" json:"pcloud_target_folder,omitempty"`
-	ChunkSize                int64       `protobuf:"varint,3,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
-	Qmfs                     *QmfsConfig `protobuf:"bytes,4,opt,name=qmfs,proto3" json:"qmfs,omitempty"`
-	XXX_NoUnkeyedLiteral     struct{}    `json:"-"`
-	XXX_unrecognized         []byte      `json:"-"`
-	XXX_sizecache            int32       `json:"-"`
+	EmptyBlobHashSanityCheck string                        `protobuf:"bytes,1,opt,name=empty_blob_hash_sanity_check,json=emptyBlobHashSanityCheck,proto3" json:"empty_blob_hash_sanity_check,omitempty"`
+	PcloudTargetFolder       string                        `protobuf:"bytes,2,opt,name=pcloud_target_folder,json=pcloudTargetFolder,proto3" json:"pcloud_target_folder,omitempty"`
+	ChunkSize                int64                         `protobuf:"varint,3,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	Qmfs                     *QmfsConfig                   `protobuf:"bytes,4,opt,name=qmfs,proto3" json:"qmfs,omitempty"`
+	LocalTargetDirectory     string                        `protobuf:"bytes,5,opt,name=local_target_directory,json=localTargetDirectory,proto3" json:"local_target_directory,omitempty"`
+	S3TargetPrefix           string                        `protobuf:"bytes,6,opt,name=s3_target_prefix,json=s3TargetPrefix,proto3" json:"s3_target_prefix,omitempty"`
+	WebdavTargetFolder       string                        `protobuf:"bytes,7,opt,name=webdav_target_folder,json=webdavTargetFolder,proto3" json:"webdav_target_folder,omitempty"`
+	HttpClient               *HttpClientConfig             `protobuf:"bytes,8,opt,name=http_client,json=httpClient,proto3" json:"http_client,omitempty"`
+	ContentDefinedChunking   *ContentDefinedChunkingConfig `protobuf:"bytes,9,opt,name=content_defined_chunking,json=contentDefinedChunking,proto3" json:"content_defined_chunking,omitempty"`
+	Upload                   *UploadConfig                 `protobuf:"bytes,10,opt,name=upload,proto3" json:"upload,omitempty"`
+	Compression              Compression                   `protobuf:"varint,11,opt,name=compression,proto3,enum=dedupb.Compression" json:"compression,omitempty"`
+	ProtocolVersion          int32                         `protobuf:"varint,12,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Padding                  Padding                       `protobuf:"varint,13,opt,name=padding,proto3,enum=dedupb.Padding" json:"padding,omitempty"`
+	HashVersion              string                        `protobuf:"bytes,14,opt,name=hash_version,json=hashVersion,proto3" json:"hash_version,omitempty"`
+	XXX_NoUnkeyedLiteral     struct{}                      `json:"-"`
+	XXX_unrecognized         []byte                        `json:"-"`
+	XXX_sizecache            int32                         `json:"-"`
 }
 
 func (m *DeduConfig) Reset()         { *m = DeduConfig{} }
 func (m *DeduConfig) String() string { return proto.CompactTextString(m) }
 func (*DeduConfig) ProtoMessage()    {}
 func (*DeduConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a41550a7431a5bcb, []int{13}
+	return fileDescriptor_a41550a7431a5bcb, []int{19}
 }
 
 func (m *DeduConfig) XXX_Unmarshal(b []byte) error {
@@ -828,6 +1397,76 @@ func (m *DeduConfig) GetQmfs() *QmfsConfig {
 	return nil
 }
 
+func (m *DeduConfig) GetLocalTargetDirectory() string {
+	if m != nil {
+		return m.LocalTargetDirectory
+	}
+	return ""
+}
+
+func (m *DeduConfig) GetS3TargetPrefix() string {
+	if m != nil {
+		return m.S3TargetPrefix
+	}
+	return ""
+}
+
+func (m *DeduConfig) GetWebdavTargetFolder() string {
+	if m != nil {
+		return m.WebdavTargetFolder
+	}
+	return ""
+}
+
+func (m *DeduConfig) GetHttpClient() *HttpClientConfig {
+	if m != nil {
+		return m.HttpClient
+	}
+	return nil
+}
+
+func (m *DeduConfig) GetContentDefinedChunking() *ContentDefinedChunkingConfig {
+	if m != nil {
+		return m.ContentDefinedChunking
+	}
+	return nil
+}
+
+func (m *DeduConfig) GetUpload() *UploadConfig {
+	if m != nil {
+		return m.Upload
+	}
+	return nil
+}
+
+func (m *DeduConfig) GetCompression() Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return Compression_COMPRESSION_NONE
+}
+
+func (m *DeduConfig) GetProtocolVersion() int32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+func (m *DeduConfig) GetPadding() Padding {
+	if m != nil {
+		return m.Padding
+	}
+	return Padding_PADDING_NONE
+}
+
+func (m *DeduConfig) GetHashVersion() string {
+	if m != nil {
+		return m.HashVersion
+	}
+	return ""
+}
+
 type DeduSecretsConfig struct {
 	HashingKey           []byte              `protobuf:"bytes,1,opt,name=hashing_key,json=hashingKey,proto3" json:"hashing_key,omitempty"`
 	EncryptionKeys       *Keyset             `protobuf:"bytes,2,opt,name=encryption_keys,json=encryptionKeys,proto3" json:"encryption_keys,omitempty"`
@@ -842,7 +1481,7 @@ func (m *DeduSecretsConfig) Reset()         { *m = DeduSecretsConfig{} }
 func (m *DeduSecretsConfig) String() string { return proto.CompactTextString(m) }
 func (*DeduSecretsConfig) ProtoMessage()    {}
 func (*DeduSecretsConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a41550a7431a5bcb, []int{14}
+	return fileDescriptor_a41550a7431a5bcb, []int{20}
 }
 
 func (m *DeduSecretsConfig) XXX_Unmarshal(b []byte) error {
@@ -892,6 +1531,8 @@ func (m *DeduSecretsConfig) GetConfig() *DeduConfig {
 }
 
 func init() {
+	proto.RegisterEnum("dedupb.Compression", Compression_name, Compression_value)
+	proto.RegisterEnum("dedupb.Padding", Padding_name, Padding_value)
 	proto.RegisterType((*ChunkMetadata)(nil), "dedupb.ChunkMetadata")
 	proto.RegisterType((*MagicHeader)(nil), "dedupb.MagicHeader")
 	proto.RegisterType((*PublicHeader)(nil), "dedupb.PublicHeader")
@@ -902,9 +1543,15 @@ func init() {
 	proto.RegisterType((*VirtualChunk)(nil), "dedupb.VirtualChunk")
 	proto.RegisterType((*LocalResourceChunk)(nil), "dedupb.LocalResourceChunk")
 	proto.RegisterType((*PcloudCredentials)(nil), "dedupb.PcloudCredentials")
+	proto.RegisterType((*LocalStorageCredentials)(nil), "dedupb.LocalStorageCredentials")
+	proto.RegisterType((*S3Credentials)(nil), "dedupb.S3Credentials")
+	proto.RegisterType((*WebdavCredentials)(nil), "dedupb.WebdavCredentials")
 	proto.RegisterType((*StorageCredentials)(nil), "dedupb.StorageCredentials")
 	proto.RegisterType((*Keyset)(nil), "dedupb.Keyset")
 	proto.RegisterType((*QmfsConfig)(nil), "dedupb.QmfsConfig")
+	proto.RegisterType((*HttpClientConfig)(nil), "dedupb.HttpClientConfig")
+	proto.RegisterType((*ContentDefinedChunkingConfig)(nil), "dedupb.ContentDefinedChunkingConfig")
+	proto.RegisterType((*UploadConfig)(nil), "dedupb.UploadConfig")
 	proto.RegisterType((*DeduConfig)(nil), "dedupb.DeduConfig")
 	proto.RegisterType((*DeduSecretsConfig)(nil), "dedupb.DeduSecretsConfig")
 }
@@ -912,66 +1559,124 @@ func init() {
 func init() { proto.RegisterFile("dedu.proto", fileDescriptor_a41550a7431a5bcb) }
 
 var fileDescriptor_a41550a7431a5bcb = []byte{
-	// 961 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xff, 0x6e, 0xdc, 0xc4,
-	0x13, 0xff, 0x5e, 0x2e, 0x71, 0x9b, 0xb9, 0xcb, 0xaf, 0x6d, 0xd3, 0x6f, 0x1a, 0xa8, 0x28, 0x46,
-	0x42, 0x4d, 0x15, 0x02, 0x0d, 0x42, 0x50, 0x09, 0x81, 0xc8, 0x41, 0x09, 0xa4, 0x85, 0xb0, 0x89,
-	0xfa, 0x1f, 0x5a, 0xed, 0xd9, 0xe3, 0xbb, 0xd5, 0xf9, 0xbc, 0xc6, 0xbb, 0x0e, 0x5c, 0xff, 0xe0,
-	0x21, 0x78, 0x17, 0xc4, 0x13, 0xf0, 0x12, 0xbc, 0x05, 0x6f, 0x80, 0x76, 0x76, 0xed, 0xf8, 0x02,
-	0x42, 0xfc, 0xe7, 0x99, 0xf9, 0xec, 0xec, 0xcc, 0x67, 0x3e, 0x3b, 0x06, 0x48, 0x31, 0xad, 0x8f,
-	0xca, 0x4a, 0x5b, 0xcd, 0x22, 0xf7, 0x5d, 0x8e, 0x63, 0x05, 0x1b, 0xa3, 0x69, 0x5d, 0xcc, 0x5e,
-	0xa0, 0x95, 0xa9, 0xb4, 0x92, 0x1d, 0xc0, 0x76, 0x5d, 0xe6, 0x5a, 0xa6, 0xc2, 0xaa, 0x39, 0x1a,
-	0x2b, 0xe7, 0xe5, 0x5e, 0xef, 0x61, 0xef, 0xd1, 0x3a, 0xdf, 0xf2, 0xfe, 0xcb, 0xc6, 0xcd, 0xde,
-	0x01, 0x66, 0xea, 0xc9, 0x04, 0x8d, 0xc5, 0x54, 0x64, 0x2a, 0xc7, 0x42, 0xce, 0x71, 0x6f, 0x85,
-	0xc0, 0x3b, 0x6d, 0xe4, 0x59, 0x08, 0xc4, 0x3f, 0xc3, 0xe0, 0x85, 0x9c, 0xa8, 0xe4, 0x14, 0x65,
-	0x8a, 0x15, 0x63, 0xb0, 0xea, 0x6a, 0x08, 0xc9, 0xe9, 0xdb, 0x5d, 0x4e, 0xe5, 0x25, 0x3a, 0x17,
-	0x57, 0x58, 0x19, 0xa5, 0x0b, 0xca, 0xb7, 0xc6, 0xb7, 0x1a, 0xff, 0x4b, 0xef, 0x66, 0xef, 0xc1,
-	0xdd, 0xb2, 0x1e, 0xe7, 0x2a, 0x11, 0x53, 0xca, 0x27, 0x72, 0x2c, 0x26, 0x76, 0xba, 0xd7, 0x27,
-	0x38, 0xf3, 0x31, 0x7f, 0xd5, 0x73, 0x8a, 0xc4, 0xdf, 0xc3, 0xf0, 0xbc, 0xe3, 0x65, 0xf7, 0xe1,
-	0x76, 0xe2, 0x5a, 0x17, 0x2a, 0x0d, 0x45, 0xdc, 0x22, 0xfb, 0xab, 0x94, 0x1d, 0xc3, 0x6e, 0x59,
-	0xa9, 0x2b, 0x69, 0xf1, 0x46, 0x76, 0x5f, 0xcc, 0x9d, 0x10, 0x5c, 0x4a, 0x7f, 0x04, 0xd1, 0xa9,
-	0x34, 0x53, 0x34, 0xae, 0x33, 0x33, 0x95, 0x4f, 0x28, 0xe9, 0x90, 0xd3, 0x37, 0xdb, 0x86, 0xfe,
-	0x3c, 0xfd, 0x80, 0xce, 0x0f, 0xb9, 0xfb, 0x8c, 0x7f, 0x5b, 0x81, 0x8d, 0xf3, 0x6e, 0x1e, 0xf6,
-	0x14, 0x36, 0xae, 0x54, 0x65, 0x6b, 0x99, 0x0b, 0x2a, 0x84, 0x12, 0x0c, 0x8e, 0xef, 0x1e, 0xf9,
-	0x59, 0x1d, 0xbd, 0xf4, 0x41, 0x9a, 0x17, 0x1f, 0x5e, 0x75, 0x2c, 0xf6, 0x19, 0x3c, 0xf0, 0xbd,
-	0x98, 0x12, 0x13, 0x95, 0xa9, 0x44, 0x60, 0x91, 0x54, 0x8b, 0xd2, 0x2a, 0x5d, 0x88, 0x19, 0x2e,
-	0xc2, 0xc5, 0xfb, 0x04, 0xba, 0x08, 0x98, 0x2f, 0x5a, 0xc8, 0x19, 0x2e, 0xd8, 0x09, 0xec, 0x68,
-	0x32, 0x64, 0x2e, 0xe6, 0x41, 0x0d, 0xc4, 0xe6, 0xe0, 0x78, 0xb7, 0xa9, 0x60, 0x49, 0x2a, 0x7c,
-	0xbb, 0xc1, 0xb7, 0xe2, 0x79, 0x0a, 0xdb, 0x65, 0x2e, 0x55, 0x61, 0xf1, 0x27, 0x2b, 0xa6, 0xc4,
-	0xc6, 0xde, 0x2a, 0xa5, 0xd8, 0x6c, 0x52, 0x78, 0x8e, 0xf8, 0x56, 0x8b, 0x0b, 0xa4, 0x1d, 0x74,
-	0x8f, 0x06, 0xb6, 0xd7, 0xc2, 0xe8, 0x1b, 0x7f, 0x60, 0xfa, 0x97, 0x1e, 0x44, 0x81, 0xb2, 0x03,
-	0x58, 0x9b, 0x3b, 0x4d, 0x05, 0xaa, 0xee, 0x34, 0xb7, 0x74, 0x84, 0xc6, 0x3d, 0x82, 0x1d, 0x42,
-	0xe4, 0x45, 0x41, 0x5c, 0x74, 0x68, 0xed, 0x8a, 0x82, 0x07, 0x0c, 0x7b, 0x17, 0x6e, 0x85, 0x21,
-	0xdf, 0xe4, 0x60, 0x69, 0x66, 0xbc, 0x41, 0xc5, 0x1f, 0xc3, 0xa6, 0x1f, 0x0c, 0x66, 0x58, 0x61,
-	0x91, 0xa0, 0x93, 0x81, 0xa3, 0xa0, 0x11, 0xb8, 0xfb, 0x66, 0xf7, 0x20, 0xea, 0x28, 0xa9, 0xcf,
-	0x83, 0x15, 0xff, 0xda, 0x83, 0x61, 0x77, 0xbc, 0xec, 0x4d, 0x18, 0x5a, 0x6d, 0x65, 0xde, 0x50,
-	0xd1, 0x23, 0xf8, 0x80, 0x7c, 0x9e, 0x06, 0x76, 0x08, 0x6b, 0x5e, 0x26, 0x2b, 0x0f, 0xfb, 0x8f,
-	0x06, 0xc7, 0xf7, 0x96, 0x86, 0xd4, 0x96, 0xc1, 0x3d, 0xe8, 0x1f, 0x47, 0xd3, 0xff, 0x6f, 0xa3,
-	0xe9, 0x3e, 0x94, 0xd5, 0xa5, 0x87, 0x12, 0xff, 0xd9, 0x03, 0xf6, 0x5c, 0x27, 0x32, 0xe7, 0x68,
-	0x74, 0x5d, 0x25, 0xe8, 0xab, 0x7f, 0x0b, 0x36, 0xaa, 0xe0, 0x10, 0xb4, 0x14, 0x3c, 0x07, 0xc3,
-	0xc6, 0xf9, 0x8d, 0x9c, 0xa3, 0xe3, 0x42, 0x67, 0x99, 0x41, 0xdb, 0x70, 0xe1, 0xad, 0x0e, 0x47,
-	0xfd, 0x2e, 0x47, 0xec, 0x31, 0xec, 0xb8, 0xba, 0x85, 0xce, 0x44, 0x5b, 0x61, 0xa8, 0x67, 0xcb,
-	0x05, 0xbe, 0xcd, 0xce, 0x1b, 0x37, 0x3b, 0x04, 0xd6, 0x60, 0x49, 0xe3, 0x9a, 0xc0, 0x6b, 0x04,
-	0xde, 0xf6, 0xe0, 0x51, 0xeb, 0xbf, 0x66, 0x32, 0x22, 0x42, 0xfe, 0x9d, 0xc9, 0xf8, 0x0c, 0x76,
-	0xce, 0x93, 0x5c, 0xd7, 0xe9, 0xa8, 0xc2, 0x14, 0x0b, 0xab, 0x64, 0x6e, 0xd8, 0x3e, 0xdc, 0xae,
-	0x0d, 0x56, 0x9d, 0x66, 0x5b, 0xdb, 0xc5, 0x4a, 0x69, 0xcc, 0x8f, 0xba, 0x4a, 0xc3, 0x76, 0x6c,
-	0xed, 0xf8, 0x4b, 0x60, 0x17, 0x56, 0x57, 0x72, 0x82, 0xdd, 0x6c, 0x4f, 0x20, 0x2a, 0xe9, 0x8a,
-	0xa0, 0xeb, 0xfb, 0xad, 0xf8, 0x6e, 0x5e, 0xcc, 0x03, 0x30, 0xfe, 0x1a, 0xa2, 0x33, 0x5c, 0x38,
-	0xfe, 0x3e, 0x82, 0xff, 0xd7, 0x45, 0x78, 0xfe, 0xe8, 0xd6, 0x78, 0x31, 0x73, 0x2b, 0xc0, 0x11,
-	0x4d, 0x1b, 0xe9, 0xf4, 0x7f, 0x7c, 0xb7, 0x03, 0xb8, 0x54, 0xc5, 0xcc, 0x9f, 0x3c, 0x89, 0x60,
-	0x75, 0xa6, 0x8a, 0x34, 0x3e, 0x00, 0xf8, 0x6e, 0x9e, 0x99, 0x91, 0x2e, 0x32, 0x35, 0x61, 0xaf,
-	0xc1, 0xfa, 0x0f, 0xf3, 0xcc, 0x88, 0x4a, 0x6b, 0xdb, 0xf4, 0xe6, 0x1c, 0x5c, 0x6b, 0x1b, 0xff,
-	0xde, 0x03, 0xf8, 0x1c, 0xd3, 0x3a, 0x60, 0x3f, 0x81, 0xd7, 0x71, 0x5e, 0xda, 0x85, 0x18, 0xe7,
-	0x7a, 0x4c, 0x32, 0x13, 0x46, 0x16, 0xca, 0x2e, 0x44, 0x32, 0xc5, 0x64, 0x16, 0x8e, 0xef, 0x11,
-	0xe6, 0x24, 0xd7, 0x63, 0xa7, 0xb0, 0x0b, 0x02, 0x8c, 0x5c, 0x9c, 0xb6, 0x3a, 0xf5, 0x23, 0xac,
-	0xac, 0x26, 0x68, 0x45, 0xa6, 0xf3, 0x14, 0xab, 0x40, 0x1b, 0xf3, 0xb1, 0x4b, 0x0a, 0x3d, 0xa3,
-	0x08, 0x7b, 0x00, 0x10, 0x36, 0x9f, 0x7a, 0x85, 0x41, 0x31, 0xeb, 0x7e, 0xcd, 0xa9, 0x57, 0xc8,
-	0xde, 0x86, 0x55, 0x57, 0x6b, 0xd8, 0x42, 0xac, 0xe1, 0xf1, 0xba, 0x3d, 0x4e, 0xf1, 0xf8, 0x8f,
-	0x1e, 0xec, 0xb8, 0x3e, 0x2e, 0x30, 0xa9, 0xd0, 0x36, 0xad, 0xbf, 0x01, 0x03, 0xd7, 0x83, 0x2a,
-	0x26, 0xb4, 0x44, 0xfd, 0x42, 0x87, 0xe0, 0x72, 0x4b, 0xf3, 0x43, 0xd8, 0x5a, 0x5e, 0xb4, 0x26,
-	0x6c, 0x97, 0xf6, 0x51, 0x79, 0x6a, 0xf9, 0x26, 0x76, 0x97, 0xad, 0x61, 0x9f, 0xc2, 0x86, 0xf1,
-	0x73, 0x17, 0x49, 0x85, 0x69, 0xf3, 0x16, 0xf7, 0x9b, 0x63, 0x7f, 0x17, 0x05, 0x1f, 0x9a, 0x6b,
-	0x9f, 0x61, 0x8f, 0x21, 0x4a, 0xa8, 0xc8, 0x9b, 0xad, 0x5d, 0x4f, 0x83, 0x07, 0xc4, 0x38, 0xa2,
-	0x9f, 0xe7, 0xfb, 0x7f, 0x05, 0x00, 0x00, 0xff, 0xff, 0x12, 0x1f, 0xbc, 0x60, 0x01, 0x08, 0x00,
-	0x00,
+	// 1903 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x57, 0xdd, 0x52, 0x23, 0xc7,
+	0xf5, 0xb7, 0x10, 0x12, 0x70, 0x24, 0x81, 0xe8, 0x05, 0x56, 0xbb, 0xb6, 0xcb, 0xfc, 0xc7, 0xff,
+	0x4d, 0x01, 0xb5, 0xde, 0xc4, 0x10, 0x9c, 0x6c, 0x55, 0x2a, 0x09, 0x08, 0x08, 0x64, 0x97, 0x8f,
+	0x8c, 0xf0, 0xee, 0x55, 0xd2, 0xd5, 0x9a, 0x69, 0x49, 0x5d, 0x9a, 0xe9, 0x9e, 0x4c, 0xb7, 0x58,
+	0xe4, 0x8b, 0x3c, 0x41, 0xae, 0x72, 0x97, 0xb7, 0xf2, 0xa5, 0x2b, 0x55, 0xc9, 0x2b, 0xa4, 0xf2,
+	0x06, 0xa9, 0xfe, 0x1a, 0x8d, 0xd8, 0xb5, 0xe3, 0xbb, 0xe9, 0xf3, 0x3b, 0xdd, 0x7d, 0x3e, 0x7e,
+	0x7d, 0xce, 0x19, 0x80, 0x98, 0xc6, 0x93, 0x17, 0x59, 0x2e, 0x94, 0x40, 0x75, 0xfd, 0x9d, 0xf5,
+	0x83, 0x6f, 0x2b, 0xd0, 0xea, 0x8e, 0x26, 0x7c, 0x7c, 0x49, 0x15, 0x89, 0x89, 0x22, 0x68, 0x17,
+	0xda, 0x93, 0x2c, 0x11, 0x24, 0xc6, 0x8a, 0xa5, 0x54, 0x2a, 0x92, 0x66, 0x9d, 0xca, 0x76, 0x65,
+	0x67, 0x25, 0x5c, 0xb3, 0xf2, 0x5b, 0x2f, 0x46, 0x5f, 0x00, 0x92, 0x93, 0xe1, 0x90, 0x4a, 0x45,
+	0x63, 0x3c, 0x60, 0x09, 0xe5, 0x24, 0xa5, 0x9d, 0x05, 0xa3, 0xbc, 0x5e, 0x20, 0x67, 0x0e, 0x40,
+	0x87, 0xb0, 0x95, 0x8a, 0x98, 0x0d, 0x58, 0x44, 0x14, 0x13, 0xbc, 0x74, 0x7e, 0xd5, 0x6c, 0xd9,
+	0x2c, 0xa3, 0xb3, 0x5b, 0x10, 0x2c, 0xa6, 0x22, 0xa6, 0x9d, 0xc5, 0xed, 0xca, 0x4e, 0x2b, 0x34,
+	0xdf, 0xe8, 0x29, 0x2c, 0x8f, 0x84, 0x54, 0xe6, 0xbe, 0x9a, 0xd9, 0x5c, 0xac, 0x83, 0xbf, 0x40,
+	0xe3, 0x92, 0x0c, 0x59, 0x74, 0x4e, 0x49, 0x4c, 0x73, 0xbd, 0x5d, 0xfb, 0xea, 0x7c, 0x30, 0xdf,
+	0xda, 0x47, 0x13, 0x86, 0x48, 0x24, 0xf8, 0x8e, 0xe6, 0x92, 0x09, 0x6e, 0xcc, 0xae, 0x85, 0x6b,
+	0x5e, 0xfe, 0xc6, 0x8a, 0xd1, 0xcf, 0x60, 0x23, 0x9b, 0xf4, 0x13, 0x16, 0xe1, 0x91, 0x39, 0x0f,
+	0x27, 0x94, 0x0f, 0xd5, 0xc8, 0x98, 0x5c, 0x0b, 0x91, 0xc5, 0xec, 0x55, 0xaf, 0x0d, 0x12, 0xfc,
+	0x11, 0x9a, 0x37, 0x25, 0x29, 0x7a, 0x02, 0xcb, 0x91, 0x8e, 0x30, 0x66, 0xb1, 0x33, 0x62, 0xc9,
+	0xac, 0x2f, 0x62, 0xb4, 0x0f, 0x9b, 0x59, 0xce, 0xee, 0x88, 0xa2, 0x0f, 0x4e, 0xb7, 0xc6, 0x3c,
+	0x72, 0xe0, 0xdc, 0xf1, 0x67, 0x50, 0x3f, 0x27, 0x72, 0x44, 0xa5, 0xf6, 0x4c, 0x8e, 0xc8, 0x97,
+	0xe6, 0xd0, 0x66, 0x68, 0xbe, 0x51, 0x1b, 0xaa, 0x69, 0x7c, 0x68, 0xf6, 0x37, 0x43, 0xfd, 0x89,
+	0xb6, 0xa0, 0x2e, 0x47, 0x64, 0xff, 0xf0, 0x2b, 0x63, 0x72, 0x33, 0x74, 0xab, 0xe0, 0xef, 0x55,
+	0x68, 0xdd, 0x94, 0xcf, 0x47, 0x2f, 0xa1, 0x75, 0xc7, 0x72, 0x35, 0x21, 0x09, 0x36, 0x06, 0x9a,
+	0x83, 0x1b, 0xfb, 0x1b, 0x2f, 0x2c, 0x57, 0x5e, 0xbc, 0xb1, 0xa0, 0xa1, 0x4b, 0xd8, 0xbc, 0x2b,
+	0xad, 0xd0, 0x11, 0x7c, 0x6a, 0x7d, 0x94, 0x19, 0x8d, 0x74, 0x0e, 0x31, 0xe5, 0x51, 0x3e, 0xcd,
+	0x4c, 0x9e, 0xc7, 0x74, 0xea, 0x0c, 0x7a, 0x6a, 0x94, 0x7a, 0x4e, 0xe7, 0xb4, 0x50, 0x79, 0x45,
+	0xa7, 0xe8, 0x18, 0xd6, 0x85, 0x59, 0x90, 0x04, 0xa7, 0x8e, 0x8c, 0xc6, 0xe4, 0xc6, 0xfe, 0xa6,
+	0xb7, 0x60, 0x8e, 0xa9, 0x61, 0xdb, 0xeb, 0x7b, 0x09, 0x7a, 0x09, 0xed, 0x2c, 0x21, 0x8c, 0x2b,
+	0x7a, 0xaf, 0xf0, 0xc8, 0x44, 0xc9, 0xd0, 0xa6, 0xb1, 0xbf, 0xea, 0x8f, 0xb0, 0xb1, 0x0b, 0xd7,
+	0x0a, 0x3d, 0x17, 0xcc, 0xdd, 0xf2, 0x56, 0x97, 0x05, 0xcd, 0xac, 0x6a, 0x49, 0xd5, 0x66, 0x00,
+	0x1d, 0x42, 0x23, 0x12, 0x69, 0x96, 0x53, 0x69, 0x88, 0x53, 0xdf, 0xae, 0xec, 0xac, 0xee, 0x3f,
+	0x2a, 0x6c, 0x9c, 0x41, 0x61, 0x59, 0x0f, 0xed, 0xc2, 0x52, 0x46, 0xe2, 0x98, 0xf1, 0x61, 0x67,
+	0xc9, 0x6c, 0x59, 0xf3, 0x5b, 0x6e, 0xac, 0x38, 0xf4, 0x78, 0xf0, 0xb7, 0x0a, 0xd4, 0x5d, 0x52,
+	0x76, 0xa1, 0x96, 0x6a, 0x36, 0xbb, 0x64, 0x14, 0xd7, 0x94, 0x28, 0x1e, 0x5a, 0x0d, 0xf4, 0x1c,
+	0xea, 0x96, 0x8e, 0x9d, 0x85, 0xf9, 0xc4, 0x95, 0xe9, 0x18, 0x3a, 0x1d, 0xf4, 0x53, 0x58, 0x72,
+	0xf4, 0x7a, 0x18, 0xe5, 0x39, 0x56, 0x84, 0x5e, 0x2b, 0x78, 0x03, 0xab, 0x36, 0xf5, 0x74, 0x40,
+	0x73, 0xca, 0x23, 0xaa, 0x09, 0xa8, 0x83, 0xec, 0x9f, 0x96, 0xfe, 0xd6, 0x74, 0x2b, 0x71, 0xb8,
+	0x1a, 0xba, 0x15, 0xea, 0xc0, 0x92, 0x63, 0x8c, 0xb9, 0x6e, 0x39, 0xf4, 0xcb, 0xe0, 0xdf, 0x15,
+	0x68, 0x96, 0xa9, 0x85, 0xfe, 0x0f, 0x9a, 0x4a, 0x28, 0x92, 0xf8, 0x34, 0x54, 0xcc, 0x41, 0x0d,
+	0x23, 0x73, 0x29, 0x78, 0x0e, 0x35, 0x4b, 0xd1, 0x85, 0xed, 0xea, 0x4e, 0x63, 0x7f, 0x6b, 0x8e,
+	0x20, 0x85, 0x81, 0xa1, 0x55, 0xfa, 0x20, 0x2d, 0xaa, 0x3f, 0x8e, 0x16, 0xe5, 0xc7, 0xbb, 0x38,
+	0xff, 0x78, 0x37, 0xa0, 0x16, 0xd3, 0xcc, 0xd1, 0xa4, 0x16, 0xda, 0x05, 0x0a, 0xa0, 0xa9, 0x0f,
+	0xc8, 0x53, 0x1a, 0x33, 0x1d, 0xdb, 0xba, 0x71, 0x76, 0x4e, 0x16, 0xfc, 0xa7, 0x02, 0xe8, 0xb5,
+	0x88, 0x48, 0x12, 0x52, 0x29, 0x26, 0x79, 0x44, 0xad, 0xdf, 0x9f, 0x43, 0x2b, 0x77, 0x02, 0x6c,
+	0x2a, 0x9b, 0x8d, 0x6b, 0xd3, 0x0b, 0xaf, 0x74, 0x11, 0xdd, 0x82, 0xba, 0x18, 0x0c, 0x24, 0x55,
+	0x3e, 0xbe, 0x76, 0x55, 0x8a, 0x7b, 0x75, 0x2e, 0xee, 0x7b, 0xb0, 0xae, 0x3d, 0xc6, 0x62, 0x80,
+	0x0b, 0xdf, 0x9c, 0x27, 0x6b, 0x1a, 0xb8, 0x1e, 0xdc, 0x78, 0x31, 0x7a, 0x0e, 0xc8, 0xeb, 0x9a,
+	0x97, 0x29, 0x8c, 0xb2, 0xad, 0xaf, 0x6d, 0xab, 0xdc, 0x2d, 0xe4, 0xb3, 0x1c, 0xd4, 0xb7, 0x2b,
+	0xff, 0x33, 0x07, 0xc1, 0x5f, 0x2b, 0xb0, 0x7e, 0x13, 0x25, 0x62, 0x12, 0x77, 0x73, 0x1a, 0x53,
+	0xae, 0x18, 0x49, 0xa4, 0xae, 0xe3, 0x13, 0x49, 0xf3, 0x92, 0xb7, 0xc5, 0x5a, 0x63, 0x19, 0x91,
+	0xf2, 0x9d, 0xc8, 0x63, 0xd7, 0x53, 0x8a, 0x35, 0xfa, 0x14, 0x80, 0x4c, 0xd4, 0x08, 0x2b, 0x31,
+	0xa6, 0xdc, 0xb5, 0x8f, 0x15, 0x2d, 0xb9, 0xd5, 0x02, 0xb4, 0x0d, 0x4d, 0x92, 0x31, 0xdc, 0x27,
+	0x92, 0xe2, 0x49, 0x9e, 0x38, 0x7f, 0x81, 0x64, 0xec, 0x98, 0x48, 0xfa, 0x75, 0x9e, 0x04, 0xbf,
+	0x85, 0xc7, 0x26, 0x03, 0x3d, 0x25, 0x72, 0x32, 0xa4, 0x65, 0x9b, 0x9e, 0xc1, 0x6a, 0x2e, 0x84,
+	0xc2, 0x31, 0xcb, 0x69, 0xa4, 0x44, 0x3e, 0x75, 0x96, 0xb5, 0xb4, 0xf4, 0xc4, 0x0b, 0x83, 0x7f,
+	0x56, 0xa0, 0xd5, 0x3b, 0x78, 0xe0, 0x0c, 0xe5, 0x71, 0x26, 0x18, 0x57, 0xde, 0x19, 0xbf, 0xd6,
+	0xe9, 0xc9, 0xe9, 0xd0, 0xf7, 0x99, 0x95, 0xd0, 0xad, 0xb4, 0xbc, 0x3f, 0x89, 0xc6, 0x54, 0x39,
+	0x27, 0xdc, 0x0a, 0x05, 0xd0, 0x22, 0x51, 0x44, 0xa5, 0xd4, 0xd5, 0x73, 0x46, 0xbe, 0x86, 0x15,
+	0xbe, 0xa2, 0xd3, 0x8b, 0x58, 0xa7, 0x56, 0xd2, 0x28, 0xa7, 0x0a, 0xcf, 0x54, 0x5d, 0xb6, 0xd6,
+	0x2c, 0x70, 0xe4, 0xb5, 0x75, 0x1b, 0xf3, 0xb5, 0x5d, 0x37, 0x4a, 0x1a, 0x63, 0xa9, 0xa6, 0x89,
+	0xa7, 0x27, 0x72, 0xd8, 0xb9, 0x81, 0x7a, 0x1a, 0x09, 0x06, 0xb0, 0xfe, 0x96, 0xf6, 0x63, 0x72,
+	0x57, 0x76, 0xf1, 0x09, 0x2c, 0x17, 0x41, 0x75, 0xbd, 0xac, 0x6f, 0x23, 0x3a, 0x97, 0xca, 0x85,
+	0x1f, 0x48, 0x65, 0x75, 0x3e, 0x95, 0xc1, 0x77, 0x15, 0x40, 0x1f, 0xc8, 0xc2, 0x97, 0x50, 0xcf,
+	0x0c, 0x5d, 0x5c, 0xe1, 0x7b, 0x52, 0x54, 0xa7, 0x87, 0x24, 0x0a, 0x9d, 0x22, 0x3a, 0x84, 0x5a,
+	0xa2, 0x73, 0xea, 0xca, 0xdf, 0x67, 0x7e, 0xc7, 0xf7, 0x24, 0x3a, 0xb4, 0xda, 0xe8, 0x19, 0x2c,
+	0xc8, 0x83, 0x87, 0x35, 0x70, 0x2e, 0xb3, 0xe1, 0x82, 0x3c, 0xd0, 0x06, 0xbd, 0x33, 0xf1, 0xe8,
+	0x2c, 0xce, 0x1b, 0xf4, 0x5e, 0x94, 0x42, 0xa7, 0x18, 0xfc, 0x1e, 0xea, 0xaf, 0xe8, 0x54, 0xbf,
+	0xce, 0x5f, 0xc2, 0xe3, 0x09, 0x77, 0x2d, 0x91, 0xea, 0xc9, 0x8a, 0x8f, 0x75, 0xb6, 0xf4, 0x33,
+	0x36, 0xdd, 0xfb, 0xfc, 0xa3, 0x70, 0xb3, 0xa4, 0x70, 0xcb, 0xf8, 0xd8, 0xee, 0x3c, 0xae, 0xc3,
+	0xe2, 0x98, 0xf1, 0x38, 0xd8, 0x05, 0xf8, 0x43, 0x3a, 0x90, 0x5d, 0xc1, 0x07, 0x6c, 0x88, 0x3e,
+	0x86, 0x95, 0x3f, 0xa7, 0x03, 0x89, 0x35, 0x25, 0x3d, 0xd7, 0xb4, 0x20, 0x14, 0x42, 0x05, 0xdf,
+	0x2d, 0x40, 0xfb, 0x5c, 0xa9, 0xac, 0x9b, 0x30, 0xca, 0x95, 0xdb, 0xd1, 0x81, 0x25, 0x3d, 0x6f,
+	0x89, 0x89, 0xd7, 0xf7, 0x4b, 0x5d, 0x6e, 0x63, 0x46, 0x12, 0xec, 0x61, 0x9b, 0xbc, 0x86, 0x96,
+	0xdd, 0x3a, 0x95, 0x7d, 0xd8, 0x54, 0x89, 0xc4, 0x23, 0xc2, 0x63, 0x39, 0x22, 0x63, 0x5a, 0xe8,
+	0xda, 0x64, 0x3e, 0x52, 0x89, 0x3c, 0xf7, 0x98, 0xdf, 0xf3, 0x15, 0x3c, 0xce, 0xa9, 0xcc, 0x04,
+	0x97, 0xc5, 0x70, 0xe3, 0x77, 0x59, 0x2e, 0x6f, 0x7a, 0xd8, 0x36, 0x1a, 0xbf, 0x6f, 0x0f, 0xd6,
+	0x59, 0x9c, 0x50, 0x1c, 0x09, 0xce, 0x8b, 0x1d, 0x8e, 0xd5, 0x1a, 0xe8, 0x0a, 0xce, 0xbd, 0xee,
+	0xc7, 0xb0, 0x92, 0xe5, 0xe2, 0x7e, 0x6a, 0xf8, 0x58, 0x77, 0xc4, 0xd2, 0x02, 0x4d, 0xc8, 0x6d,
+	0x68, 0x46, 0x04, 0x47, 0x34, 0x57, 0x66, 0x36, 0x35, 0x4d, 0x77, 0x25, 0x84, 0x88, 0x74, 0x69,
+	0xae, 0xf4, 0x50, 0xaa, 0x1f, 0x05, 0xe3, 0x92, 0x46, 0x93, 0x9c, 0x62, 0x39, 0x66, 0x99, 0x9e,
+	0x05, 0xd9, 0x60, 0xda, 0x59, 0xb6, 0x8f, 0xc2, 0x63, 0xbd, 0x31, 0xcb, 0xde, 0x18, 0x24, 0x10,
+	0xf0, 0x49, 0x57, 0x70, 0x45, 0xb9, 0x3a, 0xa1, 0x03, 0xc6, 0x69, 0x6c, 0x8a, 0x1d, 0xe3, 0x43,
+	0x17, 0xe5, 0x27, 0xb0, 0x9c, 0x32, 0x8e, 0x25, 0xfb, 0x86, 0xba, 0xb6, 0xb5, 0x94, 0x32, 0xde,
+	0x63, 0xdf, 0x50, 0x0d, 0x91, 0xbb, 0xa1, 0x85, 0x6c, 0xe9, 0x5e, 0x22, 0x77, 0x43, 0x0f, 0xa5,
+	0xe4, 0xde, 0x42, 0x55, 0xb7, 0x8b, 0xdc, 0x6b, 0x28, 0xf8, 0x57, 0x05, 0x9a, 0x5f, 0x9b, 0xb1,
+	0xdb, 0xdd, 0xf0, 0x39, 0xb4, 0x6c, 0x43, 0x7a, 0x27, 0xf2, 0x31, 0xcd, 0xa5, 0xb9, 0xa6, 0x16,
+	0x36, 0x8d, 0xf0, 0xad, 0x95, 0xe9, 0x94, 0x66, 0x24, 0x9a, 0xe9, 0xd8, 0x71, 0xb2, 0xa1, 0x65,
+	0x5e, 0xe5, 0x19, 0xac, 0xba, 0x31, 0xdf, 0x2b, 0xd9, 0x89, 0xb6, 0x65, 0xa5, 0x5e, 0xed, 0x0b,
+	0x78, 0xa4, 0x4d, 0x63, 0x1c, 0x0f, 0x12, 0x36, 0x1c, 0x29, 0xdc, 0x9f, 0x2a, 0x37, 0x54, 0x55,
+	0xc3, 0x76, 0x4a, 0xee, 0x2f, 0xf8, 0x99, 0x01, 0x8e, 0xb5, 0x5c, 0x47, 0x54, 0xab, 0xa7, 0x84,
+	0xb3, 0x01, 0x95, 0x0a, 0x53, 0xae, 0x72, 0x46, 0xa5, 0x6b, 0x91, 0x28, 0x25, 0xf7, 0x97, 0x0e,
+	0x3a, 0xb5, 0x48, 0xf0, 0x6d, 0x0d, 0xe0, 0x84, 0xc6, 0x13, 0xe7, 0xde, 0xaf, 0xe1, 0x13, 0x9a,
+	0x66, 0x6a, 0x8a, 0xfb, 0x89, 0xe8, 0x9b, 0x5e, 0x8d, 0x25, 0xe1, 0x4c, 0x4d, 0x71, 0x34, 0xa2,
+	0xd1, 0xd8, 0x71, 0xb7, 0x63, 0x74, 0x8e, 0x13, 0xd1, 0xd7, 0x6d, 0xba, 0x67, 0x14, 0xba, 0x1a,
+	0x37, 0xe3, 0xba, 0xa9, 0x06, 0x58, 0x91, 0x7c, 0x48, 0x15, 0x1e, 0x88, 0x24, 0xa6, 0xb9, 0x23,
+	0x35, 0xb2, 0xd8, 0xad, 0x81, 0xce, 0x0c, 0xa2, 0x5b, 0x89, 0x1b, 0x5d, 0x67, 0xe1, 0x5f, 0xb1,
+	0x73, 0xaa, 0xce, 0xcd, 0x4f, 0x60, 0x51, 0x3f, 0x2c, 0xf7, 0xe8, 0x91, 0x7f, 0xf4, 0xb3, 0xb7,
+	0x18, 0x1a, 0x1c, 0xfd, 0x1c, 0xb6, 0x4c, 0x39, 0xf1, 0xf7, 0xce, 0xba, 0x87, 0xe5, 0xee, 0x86,
+	0x41, 0xed, 0xcd, 0x45, 0x13, 0x41, 0x3b, 0xd0, 0x96, 0x07, 0x7e, 0x4b, 0x96, 0xd3, 0x01, 0xbb,
+	0x77, 0x3c, 0x5e, 0x95, 0x07, 0x56, 0xf9, 0xc6, 0x48, 0xb5, 0x63, 0xb6, 0xaa, 0x3c, 0x70, 0xcc,
+	0xb2, 0x1a, 0x59, 0x6c, 0xce, 0xb1, 0x97, 0xd0, 0x18, 0x29, 0x95, 0xe1, 0xc8, 0x94, 0x01, 0x43,
+	0xea, 0xc6, 0x7e, 0xa7, 0x18, 0x78, 0x1e, 0x14, 0x88, 0x10, 0x46, 0x85, 0x04, 0xfd, 0x09, 0x3a,
+	0x91, 0xa5, 0x39, 0x8e, 0x2d, 0xcf, 0xed, 0x1f, 0x81, 0x9e, 0x5d, 0x57, 0xcc, 0x39, 0xff, 0x3f,
+	0x1b, 0x77, 0xbf, 0xff, 0x39, 0x84, 0x5b, 0xd1, 0x07, 0x51, 0x3d, 0xa9, 0x5a, 0x9a, 0x75, 0x60,
+	0x7e, 0x52, 0x2d, 0x53, 0x3d, 0x74, 0x3a, 0x0f, 0xe7, 0xed, 0xc6, 0x8f, 0x9e, 0xb7, 0xdf, 0xff,
+	0xc9, 0x6b, 0x7e, 0xf8, 0x27, 0xaf, 0x34, 0x9a, 0xb7, 0x7e, 0x78, 0x34, 0xd7, 0x4f, 0xcb, 0xb0,
+	0xd2, 0x9f, 0xb8, 0x6a, 0xab, 0xa5, 0x96, 0xb9, 0xd3, 0x82, 0x7f, 0x54, 0x60, 0x5d, 0x53, 0xba,
+	0x67, 0x7a, 0xb0, 0x2f, 0xd9, 0x9f, 0x81, 0x51, 0x62, 0x7c, 0x68, 0xfa, 0xb4, 0xfd, 0x69, 0x03,
+	0x27, 0xd2, 0x2d, 0xfa, 0x17, 0xb0, 0x36, 0xff, 0xd3, 0x24, 0x3b, 0x0b, 0xf3, 0x43, 0xaa, 0x6d,
+	0x09, 0xe1, 0x2a, 0x2d, 0xff, 0x38, 0x49, 0xf4, 0x1b, 0x68, 0x49, 0xdb, 0xdd, 0x70, 0x94, 0xd3,
+	0xd8, 0xcf, 0xb6, 0x4f, 0x8b, 0x5e, 0xf6, 0x7e, 0xeb, 0x6b, 0xca, 0x99, 0x4c, 0xa2, 0x3d, 0xa8,
+	0x47, 0xc6, 0xc8, 0x87, 0x2c, 0x9f, 0x3d, 0xcc, 0xd0, 0x69, 0xec, 0xfd, 0x0a, 0x1a, 0xa5, 0x88,
+	0xa3, 0x0d, 0x68, 0x77, 0xaf, 0x2f, 0x6f, 0xc2, 0xd3, 0x5e, 0xef, 0xe2, 0xfa, 0x0a, 0x5f, 0x5d,
+	0x5f, 0x9d, 0xb6, 0x3f, 0x42, 0x8f, 0xe1, 0x51, 0x59, 0x7a, 0x72, 0x7a, 0xf6, 0xfa, 0xe8, 0xf6,
+	0xb4, 0x5d, 0xd9, 0x3b, 0x87, 0x25, 0x17, 0x51, 0xd4, 0x86, 0xe6, 0xcd, 0xd1, 0xc9, 0xc9, 0xc5,
+	0xd5, 0xef, 0xfc, 0xae, 0x75, 0x68, 0x79, 0xc9, 0xcd, 0xd1, 0xc9, 0xe5, 0x69, 0xbb, 0x82, 0x3a,
+	0xb0, 0x51, 0x88, 0xae, 0xdf, 0x9e, 0x86, 0xf8, 0xfa, 0x0c, 0xdf, 0xbe, 0xbd, 0x6e, 0x2f, 0xf4,
+	0xeb, 0x26, 0x87, 0x07, 0xff, 0x1d, 0x00, 0xde, 0xe6, 0x48, 0x8d, 0xd5, 0x10, 0x00, 0x00,
 }
@@ -13,6 +13,7 @@ require (
 	github.com/steinarvk/orc v0.0.0-20240604044022-95f5e272fcd9
 	github.com/steinarvk/orclib v0.0.0-20240604043130-5cd8130f3241
 	golang.org/x/crypto v0.23.0
+	golang.org/x/net v0.25.0
 )
 
 require (
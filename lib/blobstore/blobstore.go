@@ -0,0 +1,74 @@
+// Package blobstore defines the interface between dedu and the remote
+// storage that holds packed chunks.
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+var (
+	AlreadyExists = errors.New("Blob already exists")
+)
+
+type BlobInfo struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// BlobStore is a flat namespace of immutable named blobs.
+//
+// Put returns AlreadyExists if a blob with the given name is already
+// stored; since blobs are named by their content hash this is not an
+// error for most callers. Get, Stat and Delete return an error satisfying
+// os.IsNotExist if the blob does not exist.
+type BlobStore interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Stat(ctx context.Context, name string) (*BlobInfo, error)
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// StreamingBlobStore is implemented by stores that can transfer blobs
+// without holding them in memory.
+type StreamingBlobStore interface {
+	BlobStore
+	PutReader(ctx context.Context, name string, r io.Reader, size int64) error
+	GetReader(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// GetReader streams a blob from store if it supports streaming, and
+// otherwise falls back to reading the whole blob into memory.
+func GetReader(ctx context.Context, store BlobStore, name string) (io.ReadCloser, error) {
+	if ss, ok := store.(StreamingBlobStore); ok {
+		return ss.GetReader(ctx, name)
+	}
+	data, err := store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// PutReader streams a blob of the given size to store if it supports
+// streaming, and otherwise falls back to reading it into memory.
+func PutReader(ctx context.Context, store BlobStore, name string, r io.Reader, size int64) error {
+	if ss, ok := store.(StreamingBlobStore); ok {
+		return ss.PutReader(ctx, name, r, size)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("Expected %d bytes for %q, got %d", size, name, len(data))
+	}
+	return store.Put(ctx, name, data)
+}
@@ -2,6 +2,7 @@ package chunker
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/steinarvk/dedu/lib/deduhash"
+	"github.com/steinarvk/dedu/lib/plainhashes"
 
 	pb "github.com/steinarvk/dedu/gen/dedupb"
 )
@@ -21,12 +23,34 @@ type Chunk struct {
 	FinalLength int64
 	Empty       bool
 	FinalHash   string
+	FinalHashes *pb.Hashes
 	Error       error
+
+	release func()
+}
+
+// Release hands the buffer holding the plaintext back to the chunker for
+// reuse. The plaintext must not be used afterwards. Calling Release is
+// optional, but must happen at most once per chunk.
+func (c Chunk) Release() {
+	if c.release != nil {
+		c.release()
+	}
 }
 
+// Chunker splits files into chunks. By default chunks are cut at fixed
+// ChunkSize offsets; if CDC is set, content-defined chunking is used
+// instead.
+//
+// Chunks are read sequentially, but hashed by Workers goroutines in
+// parallel (one, if Workers is zero).
 type Chunker struct {
 	Hasher    *deduhash.Hasher
 	ChunkSize int64
+	CDC       *CDCParams
+	Workers   int
+
+	buffers sync.Pool
 }
 
 const (
@@ -34,151 +58,353 @@ const (
 	DefaultChunkSize = 1024 * 1024
 )
 
-func (c *Chunker) ReadFile(name string) <-chan Chunk {
+// Budget limits the memory held in chunk plaintexts.
+type Budget interface {
+	// Acquire blocks until n bytes are available, or ctx is cancelled.
+	Acquire(ctx context.Context, n int64) error
+	// Release makes n previously acquired bytes available again.
+	Release(n int64)
+}
+
+// MaxChunkSize returns the largest size a chunk can have.
+func (c *Chunker) MaxChunkSize() int64 {
+	if c.CDC != nil {
+		return c.CDC.MaxSize
+	}
+	if c.ChunkSize == 0 {
+		return DefaultChunkSize
+	}
+	return c.ChunkSize
+}
+
+// ReadFile reads and chunks the named file. See Read.
+func (c *Chunker) ReadFile(ctx context.Context, name string) <-chan Chunk {
+	return c.ReadFileWithBudget(ctx, name, nil)
+}
+
+// ReadFileWithBudget is like ReadFile, but acquires the memory for each
+// chunk from budget (if not nil) before reading it. The len(Plaintext)
+// bytes of each chunk sent stay acquired until the caller releases them,
+// which may be after Chunk.Release if the caller still holds something as
+// large as the plaintext. Bytes acquired for chunks that are never sent,
+// because reading fails or ctx is cancelled, are not released.
+//
+// One chunk is held back until the next has been read, so budget must
+// allow at least 2*MaxChunkSize() bytes. Content-defined chunking also
+// reads up to CDC.MaxSize bytes ahead, outside the budget.
+func (c *Chunker) ReadFileWithBudget(ctx context.Context, name string, budget Budget) <-chan Chunk {
 	path, err := filepath.Abs(name)
 	if err != nil {
-		returnError(err)
+		return returnError(err)
 	}
 
+	f, err := os.Open(path)
+	if err != nil {
+		return returnError(err)
+	}
+
+	return c.start(ctx, path, f, budget, func() {
+		f.Close()
+		logrus.Infof("All done in ReadFile(%q)", name)
+	})
+}
+
+func returnError(err error) <-chan Chunk {
+	outCh := make(chan Chunk, 1)
+	outCh <- Chunk{Error: err}
+	close(outCh)
+	return outCh
+}
+
+// Read splits the data from r into chunks, and sends them on the returned
+// channel followed by a final chunk carrying the hash, plaintext hashes
+// and length of the whole input. If anything fails, a chunk with Error set is sent and the
+// channel is closed.
+//
+// The caller must either drain the channel or cancel ctx. Once ctx is
+// cancelled, all goroutines started by Read exit promptly (after any read
+// from r in progress returns), and the channel is closed.
+func (c *Chunker) Read(ctx context.Context, name string, r io.Reader) <-chan Chunk {
+	return c.start(ctx, name, r, nil, nil)
+}
+
+func (c *Chunker) start(ctx context.Context, name string, r io.Reader, budget Budget, cleanup func()) <-chan Chunk {
 	outCh := make(chan Chunk, bufSize)
 
-	go func() {
-		defer func() {
-			logrus.Infof("All done in ReadFile(%q)", name)
-			close(outCh)
-		}()
+	send := func(chunk Chunk) bool {
+		select {
+		case outCh <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
 
-		f, err := os.Open(path)
-		if err != nil {
-			outCh <- Chunk{Error: err}
-			return
+	go func() {
+		defer close(outCh)
+		if cleanup != nil {
+			defer cleanup()
 		}
-		defer f.Close()
 
-		for chunk := range c.Read(path, f) {
-			outCh <- chunk
+		if err := c.read(ctx, name, r, budget, send); err != nil {
+			if ctx.Err() != nil {
+				logrus.Infof("Chunking of %q cancelled: %v", name, err)
+				return
+			}
+			send(Chunk{Error: err})
 		}
 	}()
 
 	return outCh
 }
 
-func returnError(err error) <-chan Chunk {
-	outCh := make(chan Chunk, 1)
-	outCh <- Chunk{Error: err}
-	return outCh
+// getBuffer returns a buffer of length n, reusing a released one if
+// possible, and a function releasing it.
+func (c *Chunker) getBuffer(n int) ([]byte, func()) {
+	var buf []byte
+	if pooled, ok := c.buffers.Get().(*[]byte); ok && cap(*pooled) >= n {
+		buf = (*pooled)[:n]
+	} else {
+		buf = make([]byte, n)
+	}
+	return buf, func() {
+		c.buffers.Put(&buf)
+	}
+}
+
+// hashJob is a chunk waiting to have its plaintext hashed.
+type hashJob struct {
+	chunk Chunk
+	done  chan struct{}
+	err   error
 }
 
-func (c *Chunker) Read(name string, r io.Reader) <-chan Chunk {
+// read does the work of Read, passing the chunks to send. It stops if
+// send returns false, which means that ctx has been cancelled. Buffers
+// are acquired from budget, if not nil, before they are filled.
+//
+// A producer goroutine reads pieces of the input and queues them, in
+// order, for the hashing workers. read itself waits for the queued chunks
+// in order and passes them on.
+func (c *Chunker) read(ctx context.Context, name string, r io.Reader, budget Budget, send func(Chunk) bool) error {
 	chunkSize := c.ChunkSize
 	if chunkSize == 0 {
 		chunkSize = DefaultChunkSize
 	}
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	var finalHash string
 	var finalHashErr error
-	wg := sync.WaitGroup{}
-	wg.Add(1)
+	finalHashes := plainhashes.NewWriter()
+	hashDone := make(chan struct{})
 	pipeReader, pipeWriter := io.Pipe()
 	r = io.TeeReader(r, pipeWriter)
 	go func() {
+		defer close(hashDone)
 		logrus.Infof("Beginning hashing of complete file %q", name)
-		finalHash, finalHashErr = c.Hasher.ComputeHash(pipeReader)
+		finalHash, finalHashErr = c.Hasher.ComputeHash(io.TeeReader(pipeReader, finalHashes))
 		logrus.Infof("Finished hashing of complete file %q", name)
-		wg.Done()
+		// If hashing stopped early, make further writes fail rather
+		// than block.
+		pipeReader.CloseWithError(fmt.Errorf("Hashing of %q stopped", name))
 	}()
 
-	outCh := make(chan Chunk, bufSize)
-
-	var nextChunk *Chunk
+	wg := sync.WaitGroup{}
+	defer func() {
+		// Stops all goroutines unless they have already finished
+		// normally, and waits for them to exit.
+		cancel()
+		pipeWriter.CloseWithError(fmt.Errorf("Chunking of %q aborted", name))
+		wg.Wait()
+		<-hashDone
+	}()
 
 	var offset int64
 
-	go func() {
-		defer func() {
-			if nextChunk != nil {
-				nextChunk.Error = fmt.Errorf("Closed prematurely")
-				outCh <- *nextChunk
-			}
-			close(outCh)
-		}()
-
-		for {
-			if nextChunk != nil {
-				outCh <- *nextChunk
-				nextChunk = nil
+	alloc := func(n int) ([]byte, func(), error) {
+		if budget != nil {
+			if err := budget.Acquire(ctx, int64(n)); err != nil {
+				return nil, nil, err
 			}
+		}
+		buf, release := c.getBuffer(n)
+		return buf, release, nil
+	}
 
+	var readNext func() ([]byte, func(), bool, error)
+	if c.CDC != nil {
+		splitter := newCDCSplitter(*c.CDC, r, alloc)
+		readNext = func() ([]byte, func(), bool, error) {
+			logrus.Infof("Reading content-defined chunk (%d-%d bytes) from offset %d of %q", c.CDC.MinSize, c.CDC.MaxSize, offset, name)
+			return splitter.next()
+		}
+	} else {
+		readNext = func() ([]byte, func(), bool, error) {
 			logrus.Infof("Reading up to %d bytes from offset %d of %q", chunkSize, offset, name)
 
-			buf := make([]byte, chunkSize)
+			buf, release, err := alloc(int(chunkSize))
+			if err != nil {
+				return nil, nil, false, err
+			}
 
 			bytesRead, err := io.ReadFull(r, buf)
 			eof := err == io.EOF || err == io.ErrUnexpectedEOF
 			if !eof && err != nil {
-				logrus.Infof("Error reading %q: %v", err)
-				outCh <- Chunk{Error: err}
+				release()
+				if budget != nil {
+					budget.Release(chunkSize)
+				}
+				return nil, nil, false, err
+			}
+			// Only what was read stays acquired.
+			if budget != nil {
+				budget.Release(chunkSize - int64(bytesRead))
+			}
+			return buf[:bytesRead], release, eof, nil
+		}
+	}
+
+	workCh := make(chan *hashJob)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range workCh {
+				job.chunk.Metadata.HashOfPlaintext, job.err = c.Hasher.ComputeHash(bytes.NewReader(job.chunk.Plaintext))
+				job.chunk.Metadata.Chunk.Hash = job.chunk.Metadata.HashOfPlaintext
+				close(job.done)
+			}
+		}()
+	}
+
+	// The queue holds the jobs in input order. Its capacity bounds how far
+	// reading can get ahead of the consumer.
+	queueCh := make(chan *hashJob, workers)
+	var readErr error
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(workCh)
+		defer close(queueCh)
+
+		for {
+			if err := ctx.Err(); err != nil {
+				readErr = err
 				return
 			}
 
-			plaintextBytes := buf[:bytesRead]
+			plaintextBytes, release, eof, err := readNext()
+			if err != nil {
+				logrus.Infof("Error reading %q: %v", name, err)
+				readErr = err
+				return
+			}
 
 			if len(plaintextBytes) > 0 {
-				plaintextHash, err := c.Hasher.ComputeHash(bytes.NewReader(plaintextBytes))
-				if err != nil {
-					outCh <- Chunk{Error: err}
-					return
-				}
-
-				md := pb.LocalResourceChunk{
-					ResourceName:    name,
-					Offset:          offset,
-					Length:          int64(len(plaintextBytes)),
-					HashOfPlaintext: plaintextHash,
-					Chunk: &pb.ChunkReference{
-						Hash:   plaintextHash,
-						Length: int64(len(plaintextBytes)),
+				job := &hashJob{
+					chunk: Chunk{
+						Metadata: &pb.LocalResourceChunk{
+							ResourceName: name,
+							Offset:       offset,
+							Length:       int64(len(plaintextBytes)),
+							Chunk: &pb.ChunkReference{
+								Length: int64(len(plaintextBytes)),
+							},
+						},
+						Plaintext: plaintextBytes,
+						release:   release,
 					},
+					done: make(chan struct{}),
 				}
 
-				nextChunk = &Chunk{
-					Metadata:  &md,
-					Plaintext: plaintextBytes,
+				select {
+				case queueCh <- job:
+				case <-ctx.Done():
+					readErr = ctx.Err()
+					return
+				}
+				select {
+				case workCh <- job:
+				case <-ctx.Done():
+					readErr = ctx.Err()
+					return
 				}
+			} else if release != nil {
+				release()
 			}
 
 			offset += int64(len(plaintextBytes))
 
 			if eof {
-				if err := pipeWriter.Close(); err != nil {
-					outCh <- Chunk{Error: err}
-					return
-				}
-				logrus.Infof("Reached EOF of %q after %d bytes", name, offset)
-				if nextChunk == nil {
-					if offset > 0 {
-						logrus.Fatalf("Sanity check violated: EOF reached with no nextChunk, but data was read")
-					}
-					nextChunk = &Chunk{
-						Empty: true,
-					}
-				}
-				nextChunk.Final = true
-				logrus.Infof("Waiting for complete-file hashing")
-				wg.Wait()
-				logrus.Infof("Done waiting for complete-file hashing")
-				if finalHashErr != nil {
-					outCh <- Chunk{Error: finalHashErr}
-					return
-				}
-				nextChunk.FinalHash = finalHash
-				nextChunk.FinalLength = offset
-				outCh <- *nextChunk
-				nextChunk = nil
-				logrus.Infof("All done with %q", name)
+				readErr = pipeWriter.Close()
 				return
 			}
 		}
 	}()
-	return outCh
+
+	// The most recent chunk is held back until we know whether it is the
+	// final one.
+	var nextChunk *Chunk
+
+	for job := range queueCh {
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if job.err != nil {
+			return job.err
+		}
+
+		if nextChunk != nil {
+			if !send(*nextChunk) {
+				return ctx.Err()
+			}
+		}
+		chunk := job.chunk
+		nextChunk = &chunk
+	}
+
+	// The queue is closed, so the producer has finished.
+	if readErr != nil {
+		return readErr
+	}
+	logrus.Infof("Reached EOF of %q after %d bytes", name, offset)
+
+	if nextChunk == nil {
+		if offset > 0 {
+			return fmt.Errorf("Sanity check violated: EOF reached with no nextChunk, but %d bytes were read", offset)
+		}
+		nextChunk = &Chunk{
+			Empty: true,
+		}
+	}
+
+	logrus.Infof("Waiting for complete-file hashing")
+	select {
+	case <-hashDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	logrus.Infof("Done waiting for complete-file hashing")
+	if finalHashErr != nil {
+		return finalHashErr
+	}
+
+	nextChunk.Final = true
+	nextChunk.FinalHash = finalHash
+	nextChunk.FinalHashes = finalHashes.Hashes()
+	nextChunk.FinalLength = offset
+	if !send(*nextChunk) {
+		return ctx.Err()
+	}
+	logrus.Infof("All done with %q", name)
+	return nil
 }
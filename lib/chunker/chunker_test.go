@@ -0,0 +1,451 @@
+package chunker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/dedu/lib/internal/chunktest"
+)
+
+// Lengths of the content-defined chunks of goldenInput with an average
+// size of 8192. These must never change: if boundaries move, new uploads
+// no longer deduplicate against existing ones.
+var goldenCDCLengths = []int64{
+	8739, 11373, 11834, 3261, 8608, 8598, 5046, 10754, 13719, 15308,
+	9806, 8967, 9075, 16931, 11308, 8548, 17267, 8716, 2686, 8287,
+	10841, 9370, 7902, 12191, 2313, 9458, 8669, 2569,
+}
+
+func goldenInput() []byte {
+	data := make([]byte, 256*1024)
+	rand.New(rand.NewSource(42)).Read(data)
+	return data
+}
+
+func newTestChunker(t *testing.T) *Chunker {
+	t.Helper()
+	return &Chunker{Hasher: chunktest.NewHasher(t), Workers: 3}
+}
+
+// readAll chunks data and returns the chunks, checking that the final
+// chunk describes all of it.
+func readAll(t *testing.T, c *Chunker, data []byte) []Chunk {
+	t.Helper()
+
+	var chunks []Chunk
+	for chunk := range c.Read(context.Background(), "test", bytes.NewReader(data)) {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 {
+		t.Fatal("no chunks")
+	}
+
+	final := chunks[len(chunks)-1]
+	wantHash, err := c.Hasher.ComputeHash(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !final.Final || final.FinalHash != wantHash || final.FinalLength != int64(len(data)) {
+		t.Errorf("final chunk has hash %q and length %d, want %q and %d", final.FinalHash, final.FinalLength, wantHash, len(data))
+	}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		if chunk.Final {
+			t.Errorf("chunk at offset %d is marked final", chunk.Metadata.Offset)
+		}
+	}
+	return chunks
+}
+
+// checkChunks checks that the chunks are consecutive pieces of data with
+// the right hashes, and returns their lengths.
+func checkChunks(t *testing.T, c *Chunker, data []byte, chunks []Chunk) []int64 {
+	t.Helper()
+
+	var lengths []int64
+	var offset int64
+	for _, chunk := range chunks {
+		if chunk.Empty {
+			continue
+		}
+		md := chunk.Metadata
+		if md.Offset != offset || md.Length != int64(len(chunk.Plaintext)) || md.Chunk.Length != md.Length {
+			t.Errorf("chunk at offset %d has metadata %v", offset, md)
+		}
+		if !bytes.Equal(chunk.Plaintext, data[offset:offset+md.Length]) {
+			t.Errorf("chunk at offset %d has the wrong plaintext", offset)
+		}
+		want, err := c.Hasher.ComputeHash(bytes.NewReader(chunk.Plaintext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md.HashOfPlaintext != want || md.Chunk.Hash != want {
+			t.Errorf("chunk at offset %d has hash %q, want %q", offset, md.HashOfPlaintext, want)
+		}
+		lengths = append(lengths, md.Length)
+		offset += md.Length
+	}
+	if offset != int64(len(data)) {
+		t.Errorf("chunks cover %d bytes, want %d", offset, len(data))
+	}
+	return lengths
+}
+
+func TestFixedSizeChunks(t *testing.T) {
+	c := newTestChunker(t)
+	c.ChunkSize = 1000
+
+	for _, tc := range []struct {
+		length int
+		want   []int64
+	}{
+		{3500, []int64{1000, 1000, 1000, 500}},
+		{3000, []int64{1000, 1000, 1000}},
+		{1, []int64{1}},
+	} {
+		data := goldenInput()[:tc.length]
+		lengths := checkChunks(t, c, data, readAll(t, c, data))
+		if !reflect.DeepEqual(lengths, tc.want) {
+			t.Errorf("%d bytes chunked as %v, want %v", tc.length, lengths, tc.want)
+		}
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	c := newTestChunker(t)
+	chunks := readAll(t, c, nil)
+	if len(chunks) != 1 || !chunks[0].Empty || chunks[0].Metadata != nil {
+		t.Errorf("empty input chunked as %+v", chunks)
+	}
+
+	c.CDC = &CDCParams{MinSize: 64, AvgSize: 128, MaxSize: 256}
+	chunks = readAll(t, c, nil)
+	if len(chunks) != 1 || !chunks[0].Empty {
+		t.Errorf("empty input chunked as %+v with CDC", chunks)
+	}
+}
+
+func TestGearTable(t *testing.T) {
+	if gearTable[0] != 0x3eed756e57fb8210 || gearTable[255] != 0x16da5969ec8f164c {
+		t.Errorf("gear table changed: starts with %x, ends with %x", gearTable[0], gearTable[255])
+	}
+}
+
+func TestCDCGoldenBoundaries(t *testing.T) {
+	c := newTestChunker(t)
+	params := CDCParams{}.WithDefaults(8192)
+	c.CDC = &params
+
+	data := goldenInput()
+	lengths := checkChunks(t, c, data, readAll(t, c, data))
+	if !reflect.DeepEqual(lengths, goldenCDCLengths) {
+		t.Errorf("chunk lengths are %v, want %v", lengths, goldenCDCLengths)
+	}
+	for i, length := range lengths[:len(lengths)-1] {
+		if length < params.MinSize || length > params.MaxSize {
+			t.Errorf("chunk %d has length %d, outside [%d, %d]", i, length, params.MinSize, params.MaxSize)
+		}
+	}
+}
+
+func TestCDCBoundariesSurviveInsertion(t *testing.T) {
+	c := newTestChunker(t)
+	params := CDCParams{}.WithDefaults(8192)
+	c.CDC = &params
+
+	data := goldenInput()
+	edited := append([]byte("some inserted bytes"), data...)
+
+	original := map[string]bool{}
+	for _, chunk := range readAll(t, c, data) {
+		if chunk.Metadata != nil {
+			original[chunk.Metadata.HashOfPlaintext] = true
+		}
+	}
+
+	var shared, total int
+	for _, chunk := range readAll(t, c, edited) {
+		if chunk.Metadata == nil {
+			continue
+		}
+		total++
+		if original[chunk.Metadata.HashOfPlaintext] {
+			shared++
+		}
+	}
+	if shared < total-2 {
+		t.Errorf("only %d of %d chunks unchanged after insertion at the start", shared, total)
+	}
+}
+
+func TestCDCParams(t *testing.T) {
+	if got := (CDCParams{}).WithDefaults(8192); got != (CDCParams{MinSize: 2048, AvgSize: 8192, MaxSize: 32768}) {
+		t.Errorf("WithDefaults(8192) = %+v", got)
+	}
+	for _, params := range []CDCParams{
+		{MinSize: 32, AvgSize: 128, MaxSize: 256},
+		{MinSize: 256, AvgSize: 128, MaxSize: 512},
+		{MinSize: 64, AvgSize: 1024, MaxSize: 512},
+		{MinSize: 64, AvgSize: 1024, MaxSize: 2 * 1024 * 1024 * 1024},
+	} {
+		if err := params.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", params)
+		}
+	}
+}
+
+// testBudget is a Budget that fails the test on overdrafts. A request
+// beyond max waits for a release, after closing blocked (if set).
+type testBudget struct {
+	t       *testing.T
+	max     int64
+	blocked chan struct{}
+
+	mu          sync.Mutex
+	cur         int64
+	changed     chan struct{}
+	blockedOnce sync.Once
+}
+
+func newTestBudget(t *testing.T, max int64) *testBudget {
+	return &testBudget{t: t, max: max, blocked: make(chan struct{}), changed: make(chan struct{})}
+}
+
+func (b *testBudget) Acquire(ctx context.Context, n int64) error {
+	for {
+		b.mu.Lock()
+		if b.cur+n <= b.max {
+			b.cur += n
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		b.blockedOnce.Do(func() { close(b.blocked) })
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *testBudget) Release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cur -= n
+	if b.cur < 0 {
+		b.t.Errorf("released %d bytes more than acquired", -b.cur)
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(buf []byte) (int, error) {
+	n, err := c.r.Read(buf)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "file.bin")
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestBudgetLimitsReading(t *testing.T) {
+	c := newTestChunker(t)
+	c.ChunkSize = 1000
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	budget := newTestBudget(t, 3*c.ChunkSize)
+	r := &countingReader{r: bytes.NewReader(goldenInput())}
+	chunks := c.start(ctx, "test", r, budget, nil)
+
+	// Nothing is released, so reading stops once three chunks are
+	// acquired.
+	<-budget.blocked
+	if n := atomic.LoadInt64(&r.n); n > budget.max {
+		t.Errorf("read %d bytes with a budget of %d", n, budget.max)
+	}
+
+	cancel()
+	for range chunks {
+	}
+}
+
+func TestBudgetAccounting(t *testing.T) {
+	for _, cdc := range []*CDCParams{nil, {MinSize: 2048, AvgSize: 8192, MaxSize: 32768}} {
+		c := newTestChunker(t)
+		c.ChunkSize = 10000
+		c.CDC = cdc
+
+		budget := newTestBudget(t, 2*c.MaxChunkSize())
+		data := goldenInput()
+		var total int64
+		for chunk := range c.ReadFileWithBudget(context.Background(), writeTestFile(t, data), budget) {
+			if chunk.Error != nil {
+				t.Fatal(chunk.Error)
+			}
+			budget.mu.Lock()
+			if budget.cur < int64(len(chunk.Plaintext)) {
+				t.Errorf("chunk of %d bytes received with %d bytes acquired", len(chunk.Plaintext), budget.cur)
+			}
+			budget.mu.Unlock()
+
+			total += int64(len(chunk.Plaintext))
+			chunk.Release()
+			budget.Release(int64(len(chunk.Plaintext)))
+		}
+
+		if total != int64(len(data)) || budget.cur != 0 {
+			t.Errorf("CDC %v: read %d of %d bytes, with %d bytes still acquired", cdc, total, len(data), budget.cur)
+		}
+	}
+}
+
+// endlessReader produces data for ever.
+type endlessReader struct {
+	rng *rand.Rand
+}
+
+func (e *endlessReader) Read(buf []byte) (int, error) {
+	return e.rng.Read(buf)
+}
+
+// failingReader returns data and then err.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (f *failingReader) Read(buf []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	n := copy(buf, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+// waitForGoroutines waits until no more than n goroutines are running.
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines still running, want at most %d:\n%s", runtime.NumGoroutine(), n, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelWithoutDraining(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		start func(*Chunker, context.Context) <-chan Chunk
+	}{
+		{"Read", func(c *Chunker, ctx context.Context) <-chan Chunk {
+			return c.Read(ctx, "test", &endlessReader{rng: rand.New(rand.NewSource(1))})
+		}},
+		{"ReadFile", func(c *Chunker, ctx context.Context) <-chan Chunk {
+			return c.ReadFile(ctx, writeTestFile(t, goldenInput()))
+		}},
+		{"CDC", func(c *Chunker, ctx context.Context) <-chan Chunk {
+			c.CDC = &CDCParams{MinSize: 256, AvgSize: 1024, MaxSize: 4096}
+			return c.Read(ctx, "test", &endlessReader{rng: rand.New(rand.NewSource(1))})
+		}},
+	} {
+		c := newTestChunker(t)
+		c.ChunkSize = 1000
+		before := runtime.NumGoroutine()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		chunks := tc.start(c, ctx)
+		if chunk := <-chunks; chunk.Error != nil {
+			t.Fatalf("%s: first chunk has error %v", tc.name, chunk.Error)
+		}
+
+		// Wait until the channel is full, so that sending blocks, and
+		// then stop reading. The producer, the hashing workers and the
+		// complete-file hashing must all exit regardless.
+		for len(chunks) < cap(chunks) {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		waitForGoroutines(t, before)
+
+		// Only what was already buffered is left on the channel.
+		n := 0
+		for range chunks {
+			n++
+		}
+		if n > bufSize {
+			t.Errorf("%s: received %d chunks after cancelling", tc.name, n)
+		}
+	}
+}
+
+func TestReadErrorIsReported(t *testing.T) {
+	diskError := errors.New("disk on fire")
+
+	for _, cdc := range []*CDCParams{nil, {MinSize: 256, AvgSize: 1024, MaxSize: 4096}} {
+		c := newTestChunker(t)
+		c.ChunkSize = 1000
+		c.CDC = cdc
+		before := runtime.NumGoroutine()
+
+		var errs []error
+		var final bool
+		for chunk := range c.Read(context.Background(), "test", &failingReader{data: goldenInput()[:5500], err: diskError}) {
+			if chunk.Error != nil {
+				errs = append(errs, chunk.Error)
+			}
+			final = final || chunk.Final
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], diskError) {
+			t.Errorf("CDC %v: got errors %v, want %v", cdc, errs, diskError)
+		}
+		if final {
+			t.Errorf("CDC %v: got a final chunk despite the error", cdc)
+		}
+		waitForGoroutines(t, before)
+	}
+
+	c := newTestChunker(t)
+	var errs []error
+	for chunk := range c.ReadFile(context.Background(), filepath.Join(t.TempDir(), "missing")) {
+		errs = append(errs, chunk.Error)
+	}
+	if len(errs) != 1 || !os.IsNotExist(errs[0]) {
+		t.Errorf("ReadFile() of a missing file sent errors %v, want one not-exist error", errs)
+	}
+}
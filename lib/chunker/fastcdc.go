@@ -0,0 +1,191 @@
+package chunker
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// CDCParams configures content-defined chunking with FastCDC. Chunk
+// boundaries are placed where a gear rolling hash of the content matches
+// a mask, so inserting or deleting bytes only moves the boundaries near
+// the change and the remaining chunks still deduplicate.
+//
+// Normalized chunking is used: a stricter mask applies before AvgSize and
+// a looser one after it, which concentrates chunk sizes around AvgSize.
+type CDCParams struct {
+	MinSize int64
+	AvgSize int64
+	MaxSize int64
+}
+
+const (
+	minCDCSize = 64
+	maxCDCSize = 1024 * 1024 * 1024
+
+	// normalizationLevel is the number of mask bits added before the
+	// average size and removed after it.
+	normalizationLevel = 2
+)
+
+// gearTable maps bytes to the random values mixed into the rolling hash.
+// It is part of the chunk boundary definition: changing it changes where
+// every file is cut, which defeats deduplication against existing uploads.
+var gearTable [256]uint64
+
+func init() {
+	// splitmix64 with a fixed seed.
+	state := uint64(0x64656475_66617374) // "dedufast"
+	for i := range gearTable {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gearTable[i] = z ^ (z >> 31)
+	}
+}
+
+// WithDefaults fills in sizes left at zero, based on the average size
+// (which itself defaults to chunkSize): the minimum is a quarter of it and
+// the maximum four times it.
+func (p CDCParams) WithDefaults(chunkSize int64) CDCParams {
+	if p.AvgSize == 0 {
+		p.AvgSize = chunkSize
+	}
+	if p.MinSize == 0 {
+		p.MinSize = p.AvgSize / 4
+	}
+	if p.MaxSize == 0 {
+		p.MaxSize = p.AvgSize * 4
+	}
+	return p
+}
+
+func (p CDCParams) Validate() error {
+	if p.MinSize < minCDCSize {
+		return fmt.Errorf("Invalid content-defined chunking parameters: min_size %d is less than %d", p.MinSize, minCDCSize)
+	}
+	if p.MaxSize > maxCDCSize {
+		return fmt.Errorf("Invalid content-defined chunking parameters: max_size %d is greater than %d", p.MaxSize, maxCDCSize)
+	}
+	if !(p.MinSize <= p.AvgSize && p.AvgSize <= p.MaxSize) {
+		return fmt.Errorf("Invalid content-defined chunking parameters: need min_size <= avg_size <= max_size (got %d, %d, %d)", p.MinSize, p.AvgSize, p.MaxSize)
+	}
+	return nil
+}
+
+// highBitsMask returns a mask of the n most significant bits. The most
+// significant bits of the gear hash depend on the most recent 64 bytes,
+// whereas the least significant ones only depend on the last few.
+func highBitsMask(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	if n >= 64 {
+		return ^uint64(0)
+	}
+	return ^uint64(0) << uint(64-n)
+}
+
+type cdcMasks struct {
+	small uint64
+	large uint64
+}
+
+func (p CDCParams) masks() cdcMasks {
+	// The average size rounded to the nearest power of two.
+	avgBits := bits.Len64(uint64(p.AvgSize)) - 1
+	if avgBits > 0 && p.AvgSize-(int64(1)<<uint(avgBits)) > (int64(1)<<uint(avgBits))/2 {
+		avgBits++
+	}
+	return cdcMasks{
+		small: highBitsMask(avgBits + normalizationLevel),
+		large: highBitsMask(avgBits - normalizationLevel),
+	}
+}
+
+// cutPoint returns the length of the chunk starting at the beginning of
+// data. If data is shorter than MaxSize it is assumed to be the end of the
+// input.
+func (p CDCParams) cutPoint(m cdcMasks, data []byte) int {
+	n := len(data)
+	if int64(n) <= p.MinSize {
+		return n
+	}
+	if int64(n) > p.MaxSize {
+		n = int(p.MaxSize)
+	}
+	normal := int(p.AvgSize)
+	if normal > n {
+		normal = n
+	}
+
+	var h uint64
+	i := int(p.MinSize)
+	for ; i < normal; i++ {
+		h = (h << 1) + gearTable[data[i]]
+		if h&m.small == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = (h << 1) + gearTable[data[i]]
+		if h&m.large == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// cdcSplitter reads from r and splits the input into content-defined
+// chunks.
+type cdcSplitter struct {
+	params CDCParams
+	masks  cdcMasks
+	r      io.Reader
+	alloc  func(int) ([]byte, func(), error)
+	buf    []byte
+	filled int
+	eof    bool
+}
+
+func newCDCSplitter(params CDCParams, r io.Reader, alloc func(int) ([]byte, func(), error)) *cdcSplitter {
+	return &cdcSplitter{
+		params: params,
+		masks:  params.masks(),
+		r:      r,
+		alloc:  alloc,
+		buf:    make([]byte, params.MaxSize),
+	}
+}
+
+// next returns the next chunk, a function releasing the buffer holding
+// it (obtained from alloc), and whether it is the last chunk.
+func (s *cdcSplitter) next() ([]byte, func(), bool, error) {
+	if !s.eof && s.filled < len(s.buf) {
+		n, err := io.ReadFull(s.r, s.buf[s.filled:])
+		s.filled += n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			s.eof = true
+		} else if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	var cut int
+	if s.eof {
+		// Everything left is buffered, so the shorter input is final.
+		cut = s.params.cutPoint(s.masks, s.buf[:s.filled])
+	} else {
+		cut = s.params.cutPoint(s.masks, s.buf)
+	}
+
+	chunk, release, err := s.alloc(cut)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	copy(chunk, s.buf[:cut])
+	s.filled = copy(s.buf, s.buf[cut:s.filled])
+
+	return chunk, release, s.eof && s.filled == 0, nil
+}
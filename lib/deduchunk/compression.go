@@ -0,0 +1,73 @@
+package deduchunk
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// compress returns plaintext compressed with the given algorithm.
+func compress(algorithm pb.Compression, plaintext []byte) ([]byte, error) {
+	switch algorithm {
+	case pb.Compression_COMPRESSION_DEFLATE:
+		buf := bytes.NewBuffer(nil)
+		w, err := flate.NewWriter(buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(plaintext); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported compression algorithm %v", algorithm)
+	}
+}
+
+// newCompressingWriter returns a writer compressing into w with the given
+// algorithm. It must be closed to flush the compressed data.
+func newCompressingWriter(algorithm pb.Compression, w io.Writer) (io.WriteCloser, error) {
+	switch algorithm {
+	case pb.Compression_COMPRESSION_DEFLATE:
+		return flate.NewWriter(w, flate.DefaultCompression)
+
+	default:
+		return nil, fmt.Errorf("Unsupported compression algorithm %v", algorithm)
+	}
+}
+
+// newDecompressingReader returns a reader decompressing r. The caller is
+// responsible for bounding how much is read from it, so that a malicious
+// chunk cannot make us decompress without bound.
+func newDecompressingReader(algorithm pb.Compression, r io.Reader) (io.Reader, error) {
+	switch algorithm {
+	case pb.Compression_COMPRESSION_NONE:
+		return r, nil
+
+	case pb.Compression_COMPRESSION_DEFLATE:
+		return &decompressingReader{r: flate.NewReader(r)}, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported compression algorithm %v", algorithm)
+	}
+}
+
+type decompressingReader struct {
+	r io.Reader
+}
+
+func (d *decompressingReader) Read(buf []byte) (int, error) {
+	n, err := d.r.Read(buf)
+	switch err.(type) {
+	case flate.CorruptInputError, flate.InternalError:
+		err = fmt.Errorf("%w: %v", ErrMalformedPayload, err)
+	}
+	return n, err
+}
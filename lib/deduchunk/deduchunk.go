@@ -2,31 +2,77 @@ package deduchunk
 
 import (
 	"bytes"
-	"crypto/md5"
-	"crypto/sha1"
 	"fmt"
 	"io"
 	"io/ioutil"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/aead/subtle"
+	"github.com/google/tink/go/core/cryptofmt"
 	"github.com/google/tink/go/insecurecleartextkeyset"
 	"github.com/google/tink/go/keyset"
+	"github.com/google/tink/go/streamingaead"
 	"github.com/google/tink/go/tink"
 	"github.com/steinarvk/dedu/lib/deduhash"
 	"github.com/steinarvk/dedu/lib/obfuscate"
+	"github.com/steinarvk/dedu/lib/plainhashes"
+
+	tinkpb "github.com/google/tink/go/proto/tink_go_proto"
 
 	pb "github.com/steinarvk/dedu/gen/dedupb"
 )
 
 const (
 	magicBlockSize = 16
+
+	// privateHeaderOverhead is how much longer the private header gets
+	// when encrypted with Encrypter, a Tink AES-GCM keyset such as
+	// generate-secrets creates: a key ID prefix, the IV and the tag. The
+	// public header records the encrypted length, and in version 3 is
+	// itself associated data of the private header, so the length must be
+	// known before encrypting.
+	privateHeaderOverhead = cryptofmt.NonRawPrefixSize + subtle.AESGCMIVSize + subtle.AESGCMTagSize
 )
 
+// Protocol versions of the packed chunk format.
+//
+// In version 1 the payload is encrypted in one shot with AES-GCM, so the
+// whole chunk must be in memory to pack or unpack it. In version 2 it is
+// encrypted with streaming AEAD (AES-GCM-HKDF, 1MB segments) instead.
+//
+// Version 3 is like version 2, except that the magic block and public
+// header are authenticated as associated data of both the private header
+// and the payload. A chunk whose public header has been altered, or which
+// is read under another chunk ID, then fails to decrypt at all.
+const (
+	ProtocolVersion1 = 1
+	ProtocolVersion2 = 2
+	ProtocolVersion3 = 3
+
+	DefaultProtocolVersion = ProtocolVersion1
+)
+
+// Packer packs plaintext into encrypted chunks, and unpacks them again.
+//
+// If Compression is set, chunk data is compressed before encryption,
+// unless that would not make it smaller. The algorithm used is recorded
+// in the private header.
+//
+// If Padding is set, the (possibly compressed) chunk data is padded with
+// zeroes before encryption, so that the size of the packed chunk reveals
+// less about the plaintext. The true length is only recorded in the
+// private header.
+//
+// New chunks are written with ProtocolVersion (DefaultProtocolVersion if
+// zero). Chunks of all known versions can be unpacked.
 type Packer struct {
-	Hasher     *deduhash.Hasher
-	Obfuscator *obfuscate.Obfuscator
-	Encrypter  tink.AEAD
+	Hasher          *deduhash.Hasher
+	Obfuscator      *obfuscate.Obfuscator
+	Encrypter       tink.AEAD
+	Compression     pb.Compression
+	Padding         pb.Padding
+	ProtocolVersion int32
 }
 
 type ExtraData struct {
@@ -34,15 +80,23 @@ type ExtraData struct {
 	Metadata     *pb.ChunkMetadata
 }
 
+func serializeKeyset(kh *keyset.Handle) ([]byte, error) {
+	keysetBuf := bytes.NewBuffer(nil)
+	keysetWriter := keyset.NewBinaryWriter(keysetBuf)
+	if err := insecurecleartextkeyset.Write(kh, keysetWriter); err != nil {
+		return nil, err
+	}
+	return keysetBuf.Bytes(), nil
+}
+
 func generateNewEncryptionKey() (tink.AEAD, []byte, error) {
 	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	keysetBuf := bytes.NewBuffer(nil)
-	keysetWriter := keyset.NewBinaryWriter(keysetBuf)
-	if err := insecurecleartextkeyset.Write(kh, keysetWriter); err != nil {
+	serialized, err := serializeKeyset(kh)
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -50,48 +104,97 @@ func generateNewEncryptionKey() (tink.AEAD, []byte, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return rv, keysetBuf.Bytes(), nil
+	return rv, serialized, nil
 }
 
-func calculateHashes(content []byte) *pb.Hashes {
-	rv := &pb.Hashes{}
-	sha1Sum := sha1.Sum(content)
-	md5Sum := md5.Sum(content)
-	rv.Sha1 = sha1Sum[:]
-	rv.Md5 = md5Sum[:]
-	return rv
+func streamingKeyTemplate() *tinkpb.KeyTemplate {
+	return streamingaead.AES256GCMHKDF1MBKeyTemplate()
 }
 
-func (p *Packer) Pack(plaintext []byte, extra *ExtraData) ([]byte, error) {
-	var chunkId string
+func generateNewStreamingEncryptionKey() (tink.StreamingAEAD, []byte, error) {
+	kh, err := keyset.NewHandle(streamingKeyTemplate())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialized, err := serializeKeyset(kh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rv, err := streamingaead.New(kh)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rv, serialized, nil
+}
+
+func loadChunkKeyset(serialized []byte) (*keyset.Handle, error) {
+	keysetReader := keyset.NewBinaryReader(bytes.NewBuffer(serialized))
+	kh, err := insecurecleartextkeyset.Read(keysetReader)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading chunk-specific encryption keys: %v", err)
+	}
+	return kh, nil
+}
+
+func (p *Packer) protocolVersion() (int32, error) {
+	switch p.ProtocolVersion {
+	case 0:
+		return DefaultProtocolVersion, nil
+	case ProtocolVersion1, ProtocolVersion2, ProtocolVersion3:
+		return p.ProtocolVersion, nil
+	default:
+		return 0, fmt.Errorf("Unsupported protocol version %d", p.ProtocolVersion)
+	}
+}
+
+func (p *Packer) chunkIdFor(plaintext []byte, extra *ExtraData) (string, error) {
 	if extra != nil && extra.VirtualChunk != nil {
 		if len(plaintext) > 0 {
-			return nil, fmt.Errorf("Virtual chunk cannot have data")
+			return "", fmt.Errorf("Virtual chunk cannot have data")
 		}
-		chunkId = extra.VirtualChunk.ChunkId
-		if chunkId == "" {
-			return nil, fmt.Errorf("Virtual chunk ID not set")
+		if extra.VirtualChunk.ChunkId == "" {
+			return "", fmt.Errorf("Virtual chunk ID not set")
 		}
-	} else {
-		h, err := p.Hasher.ComputeHash(bytes.NewReader(plaintext))
-		if err != nil {
-			return nil, err
+		if err := checkManifestSize(extra.VirtualChunk); err != nil {
+			return "", err
 		}
-		chunkId = h
+		return extra.VirtualChunk.ChunkId, nil
+	}
+	return p.Hasher.ComputeHash(bytes.NewReader(plaintext))
+}
+
+func (p *Packer) Pack(plaintext []byte, extra *ExtraData) ([]byte, error) {
+	version, err := p.protocolVersion()
+	if err != nil {
+		return nil, err
 	}
 
-	chunkKey, chunkKeySerialized, err := generateNewEncryptionKey()
+	chunkId, err := p.chunkIdFor(plaintext, extra)
 	if err != nil {
 		return nil, err
 	}
 
 	privateHeader := pb.PrivateHeader{
-		ChunkSpecificEncryptionKey: chunkKeySerialized,
-		PlaintextLength:            int32(len(plaintext)),
+		PlaintextLength: int64(len(plaintext)),
+		Padding:         p.Padding,
 	}
 
 	if len(plaintext) > 0 {
-		privateHeader.PlaintextHashes = calculateHashes(plaintext)
+		privateHeader.PlaintextHashes = plainhashes.Sum(plaintext)
+	}
+
+	payload := plaintext
+	if p.Compression != pb.Compression_COMPRESSION_NONE && len(plaintext) > 0 {
+		compressed, err := compress(p.Compression, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) < len(plaintext) {
+			payload = compressed
+			privateHeader.Compression = p.Compression
+		}
 	}
 
 	if extra != nil {
@@ -99,19 +202,167 @@ func (p *Packer) Pack(plaintext []byte, extra *ExtraData) ([]byte, error) {
 		privateHeader.OptionalMetadata = extra.Metadata
 	}
 
-	privateHeaderPlaintextBytes, err := proto.Marshal(&privateHeader)
+	buf := bytes.NewBuffer(nil)
+
+	if version != ProtocolVersion1 {
+		// The payload has already been compressed, if that pays off.
+		if err := p.writeStreaming(buf, version, chunkId, &privateHeader, bytes.NewReader(payload), int64(len(payload)), false); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	chunkKey, chunkKeySerialized, err := generateNewEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	privateHeader.ChunkSpecificEncryptionKey = chunkKeySerialized
+
+	if _, err := p.writeHeaders(buf, version, chunkId, &privateHeader); err != nil {
+		return nil, err
+	}
+
+	pad, err := padding(privateHeader.Padding, int64(len(payload)))
 	if err != nil {
 		return nil, err
 	}
+	if len(pad) > 0 {
+		payload = append(append(make([]byte, 0, len(payload)+len(pad)), payload...), pad...)
+	}
 
-	privateHeaderCryptotextBytes, err := p.Encrypter.Encrypt(privateHeaderPlaintextBytes, nil)
+	cryptotext, err := chunkKey.Encrypt(payload, nil)
 	if err != nil {
 		return nil, err
 	}
+	buf.Write(cryptotext)
+
+	return buf.Bytes(), nil
+}
+
+// PackStream packs length bytes of plaintext read from r, writing the
+// packed chunk to w as it goes. It uses protocol version 3 if that is the
+// configured version, and version 2 otherwise, since version 1 cannot be
+// written as a stream.
+//
+// Since the headers are written first, the chunk ID must be known in
+// advance, and the plaintext hashes are not recorded. If Compression is
+// set it is always applied, since whether it pays off is not known until
+// the end. If r does not provide exactly length bytes an error is
+// returned, and what was written to w must be discarded.
+func (p *Packer) PackStream(w io.Writer, r io.Reader, chunkId string, length int64, extra *ExtraData) error {
+	if chunkId == "" {
+		return fmt.Errorf("Chunk ID not set")
+	}
+	if length < 0 {
+		return fmt.Errorf("Unsupported plaintext length %d", length)
+	}
+	if extra != nil && extra.VirtualChunk != nil {
+		if length > 0 {
+			return fmt.Errorf("Virtual chunk cannot have data")
+		}
+		if extra.VirtualChunk.ChunkId != chunkId {
+			return fmt.Errorf("Virtual chunk ID %q does not match chunk ID %q", extra.VirtualChunk.ChunkId, chunkId)
+		}
+		if err := checkManifestSize(extra.VirtualChunk); err != nil {
+			return err
+		}
+	}
+
+	version, err := p.protocolVersion()
+	if err != nil {
+		return err
+	}
+	if version < ProtocolVersion2 {
+		version = ProtocolVersion2
+	}
+
+	privateHeader := pb.PrivateHeader{
+		PlaintextLength: length,
+		Padding:         p.Padding,
+	}
+	if length > 0 {
+		privateHeader.Compression = p.Compression
+	}
+	if extra != nil {
+		privateHeader.VirtualChunk = extra.VirtualChunk
+		privateHeader.OptionalMetadata = extra.Metadata
+	}
+
+	return p.writeStreaming(w, version, chunkId, &privateHeader, r, length, privateHeader.Compression != pb.Compression_COMPRESSION_NONE)
+}
+
+// writeStreaming writes a chunk of version 2 or later to w, encrypted
+// with streaming AEAD. Exactly length bytes are read from r, compressed
+// as they are written if compress is set, and then padded. Otherwise r
+// holds the payload as is (compressed, if the private header says so).
+func (p *Packer) writeStreaming(w io.Writer, version int32, chunkId string, privateHeader *pb.PrivateHeader, r io.Reader, length int64, compress bool) error {
+	chunkKey, chunkKeySerialized, err := generateNewStreamingEncryptionKey()
+	if err != nil {
+		return err
+	}
+	privateHeader.ChunkSpecificEncryptionKey = chunkKeySerialized
+
+	associatedData, err := p.writeHeaders(w, version, chunkId, privateHeader)
+	if err != nil {
+		return err
+	}
+
+	encrypter, err := chunkKey.NewEncryptingWriter(w, associatedData)
+	if err != nil {
+		return err
+	}
+	counter := &countingWriter{w: encrypter}
+
+	var dest io.Writer = counter
+	var compressor io.WriteCloser
+	if compress {
+		compressor, err = newCompressingWriter(privateHeader.Compression, counter)
+		if err != nil {
+			return err
+		}
+		dest = compressor
+	}
+
+	n, err := io.Copy(dest, io.LimitReader(r, length+1))
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return fmt.Errorf("Read %d bytes of plaintext (expected %d)", n, length)
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return err
+		}
+	}
+
+	pad, err := padding(privateHeader.Padding, counter.n)
+	if err != nil {
+		return err
+	}
+	if _, err := encrypter.Write(pad); err != nil {
+		return err
+	}
+	return encrypter.Close()
+}
+
+// writeHeaders writes the magic block, the public header and the private
+// header. It returns the associated data the payload must be encrypted
+// with.
+func (p *Packer) writeHeaders(w io.Writer, version int32, chunkId string, privateHeader *pb.PrivateHeader) ([]byte, error) {
+	privateHeaderPlaintextBytes, err := proto.Marshal(privateHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	privateHeaderLength := len(privateHeaderPlaintextBytes) + privateHeaderOverhead
+	if privateHeaderLength > MaxPrivateHeaderLength {
+		return nil, fmt.Errorf("Private header of %d bytes exceeds the maximum of %d", privateHeaderLength, MaxPrivateHeaderLength)
+	}
 
 	publicHeader := pb.PublicHeader{
 		ChunkId:             chunkId,
-		PrivateHeaderLength: int32(len(privateHeaderCryptotextBytes)),
+		PrivateHeaderLength: int32(privateHeaderLength),
 	}
 
 	publicHeaderPlaintextBytes, err := proto.Marshal(&publicHeader)
@@ -125,10 +376,13 @@ func (p *Packer) Pack(plaintext []byte, extra *ExtraData) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(publicHeaderObfuscatedBytes) > MaxPublicHeaderLength {
+		return nil, fmt.Errorf("Public header of %d bytes exceeds the maximum of %d", len(publicHeaderObfuscatedBytes), MaxPublicHeaderLength)
+	}
 
 	magicHeader := pb.MagicHeader{
 		Dedu:               "DEDU",
-		ProtocolVersion:    1,
+		ProtocolVersion:    version,
 		PublicHeaderLength: int32(len(publicHeaderObfuscatedBytes)),
 	}
 
@@ -146,109 +400,330 @@ func (p *Packer) Pack(plaintext []byte, extra *ExtraData) ([]byte, error) {
 		return nil, fmt.Errorf("Sanity check failed: produced header block of length %d", len(magicHeaderObfuscatedBlock))
 	}
 
-	headers := append(magicHeaderObfuscatedBlock, append(publicHeaderObfuscatedBytes, privateHeaderCryptotextBytes...)...)
-
-	cryptotext, err := chunkKey.Encrypt(plaintext, nil)
+	associatedData := headerAssociatedData(version, magicHeaderObfuscatedBlock, publicHeaderObfuscatedBytes)
+	privateHeaderCryptotextBytes, err := p.Encrypter.Encrypt(privateHeaderPlaintextBytes, associatedData)
 	if err != nil {
 		return nil, err
 	}
 
-	return append(headers, cryptotext...), nil
+	for _, data := range [][]byte{magicHeaderObfuscatedBlock, publicHeaderObfuscatedBytes, privateHeaderCryptotextBytes} {
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	return associatedData, nil
+}
+
+// headerAssociatedData returns the associated data for the private header
+// and payload of a chunk with the given (obfuscated) magic block and
+// public header: nil before version 3, and the two concatenated from it.
+func headerAssociatedData(version int32, magicBlock, publicHeader []byte) []byte {
+	if version < ProtocolVersion3 {
+		return nil
+	}
+	rv := make([]byte, 0, len(magicBlock)+len(publicHeader))
+	rv = append(rv, magicBlock...)
+	return append(rv, publicHeader...)
 }
 
-func (p *Packer) Unpack(packed []byte) ([]byte, *pb.Header, error) {
-	rv, hdr, err := p.unpack(packed)
+// Unpack unpacks a chunk, which must have the given chunk ID. Errors
+// are of type *Error.
+func (p *Packer) Unpack(packed []byte, chunkId string) ([]byte, *pb.Header, error) {
+	r, hdr, err := p.unpackStream(bytes.NewReader(packed), chunkId)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Invalid chunk: %v", err)
+		return nil, nil, chunkError(chunkId, err)
+	}
+	rv, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, chunkError(chunkId, err)
 	}
 	return rv, hdr, nil
 }
 
-func (p *Packer) unpack(packed []byte) ([]byte, *pb.Header, error) {
-	r := bytes.NewReader(packed)
+// UnpackStream reads the headers of a packed chunk from r, and returns
+// them along with a reader of the plaintext. The chunk must have the given
+// chunk ID; this is checked before anything is decrypted.
+//
+// For version 2 chunks the plaintext is decrypted as it is read. It is
+// verified against the chunk ID once all of it has been read: if that
+// fails, the reader returns an error instead of io.EOF, and the caller
+// must discard what was read. Version 1 chunks are read and verified
+// completely before UnpackStream returns.
+//
+// Errors, including those from the reader, are of type *Error.
+func (p *Packer) UnpackStream(r io.Reader, chunkId string) (io.Reader, *pb.Header, error) {
+	rv, hdr, err := p.unpackStream(r, chunkId)
+	if err != nil {
+		return nil, nil, chunkError(chunkId, err)
+	}
+	return &errorReader{r: rv, chunkId: chunkId}, hdr, nil
+}
+
+func (p *Packer) unpackStream(r io.Reader, chunkId string) (io.Reader, *pb.Header, error) {
+	if chunkId == "" {
+		return nil, nil, fmt.Errorf("%w: expected chunk ID not set", ErrWrongChunk)
+	}
+
+	header, associatedData, err := p.readHeaders(r, chunkId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kh, err := loadChunkKeyset(header.Private.ChunkSpecificEncryptionKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMalformedHeader, err)
+	}
+
+	var payload io.Reader
+	switch header.Magic.ProtocolVersion {
+	case ProtocolVersion1:
+		chunkSpecificCrypter, err := aead.New(kh)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: error loading chunk-specific encryption keys: %v", ErrMalformedHeader, err)
+		}
+
+		rest, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		decrypted, err := chunkSpecificCrypter.Decrypt(rest, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrPayloadDecrypt, err)
+		}
+
+		plaintextReader, err := p.newPlaintextReader(header, bytes.NewReader(decrypted))
+		if err != nil {
+			return nil, nil, err
+		}
+		plaintext, err := ioutil.ReadAll(plaintextReader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewReader(plaintext), header, nil
+
+	case ProtocolVersion2, ProtocolVersion3:
+		chunkSpecificCrypter, err := streamingaead.New(kh)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: error loading chunk-specific encryption keys: %v", ErrMalformedHeader, err)
+		}
+
+		decrypter, err := chunkSpecificCrypter.NewDecryptingReader(r, associatedData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrPayloadDecrypt, truncatedOr(err, "reading stream header"))
+		}
+		payload = &decryptingReader{decrypter}
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Magic.ProtocolVersion)
+	}
+
+	plaintextReader, err := p.newPlaintextReader(header, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintextReader, header, nil
+}
+
+// newPlaintextReader returns a reader of the plaintext of a chunk, given
+// its decrypted payload, which verifies the plaintext at the end.
+func (p *Packer) newPlaintextReader(header *pb.Header, payload io.Reader) (io.Reader, error) {
+	plaintext, err := newPayloadReader(header.Private, payload)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := p.Hasher.NewWriterFor(header.Public.ChunkId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHeader, err)
+	}
+	return &verifyingReader{
+		r:      plaintext,
+		header: header,
+		hasher: hasher,
+		hashes: plainhashes.NewWriter(),
+	}, nil
+}
+
+// InspectHeader decodes the headers of a packed chunk, without decrypting
+// or verifying the payload. Only the prefix of the packed chunk holding
+// the headers is needed. The chunk ID in the public header is not checked
+// against anything, nor is the chunk-specific encryption key cleared from
+// the private header. Errors are of type *Error.
+func (p *Packer) InspectHeader(prefix []byte) (*pb.Header, error) {
+	return p.InspectHeaderFrom(bytes.NewReader(prefix))
+}
+
+// InspectHeaderFrom is like InspectHeader, but reads the headers from r,
+// and reads nothing beyond them.
+func (p *Packer) InspectHeaderFrom(r io.Reader) (*pb.Header, error) {
+	header, _, err := p.readHeaders(r, "")
+	if err != nil {
+		return nil, chunkError("", err)
+	}
+	return header, nil
+}
+
+// HeaderSize returns the number of bytes taken up by the headers of a
+// packed chunk. The rest is the encrypted payload.
+func HeaderSize(header *pb.Header) int64 {
+	return magicBlockSize + int64(header.Magic.PublicHeaderLength) + int64(header.Public.PrivateHeaderLength)
+}
 
+// readHeaders reads the magic block, the public header and the private
+// header from r, leaving r positioned at the start of the payload. It
+// also returns the associated data the payload was encrypted with. The
+// chunk ID in the public header must be chunkId, unless that is empty.
+func (p *Packer) readHeaders(r io.Reader, chunkId string) (*pb.Header, []byte, error) {
 	emptyPassword := ""
 
 	magicBlockObfuscatedBytes := make([]byte, magicBlockSize)
 	if _, err := io.ReadFull(r, magicBlockObfuscatedBytes); err != nil {
-		return nil, nil, err
+		return nil, nil, truncatedOr(err, "reading magic block")
 	}
 	magicBlockBytes, err := p.Obfuscator.UnobfuscateBlock(magicBlockObfuscatedBytes, emptyPassword)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Bad magic block obfuscation")
+		return nil, nil, fmt.Errorf("%w: bad obfuscation", ErrBadMagic)
 	}
 	magicBlock := pb.MagicHeader{}
 	if err := proto.Unmarshal(magicBlockBytes, &magicBlock); err != nil {
-		return nil, nil, fmt.Errorf("Unable to parse magic block: %v", err)
+		return nil, nil, fmt.Errorf("%w: %v", ErrBadMagic, err)
 	}
-	if magicBlock.ProtocolVersion != 1 {
-		return nil, nil, fmt.Errorf("Magic block had unknown ProtocolVersion: %d", magicBlock.ProtocolVersion)
+	if magicBlock.Dedu != "DEDU" {
+		return nil, nil, fmt.Errorf("%w: %q", ErrBadMagic, magicBlock.Dedu)
 	}
-	if magicBlock.PublicHeaderLength <= 0 {
-		return nil, nil, fmt.Errorf("Magic block had bad public header length: %d", magicBlock.PublicHeaderLength)
+	if magicBlock.ProtocolVersion < ProtocolVersion1 || magicBlock.ProtocolVersion > ProtocolVersion3 {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, magicBlock.ProtocolVersion)
+	}
+	if magicBlock.PublicHeaderLength <= 0 || magicBlock.PublicHeaderLength > MaxPublicHeaderLength {
+		return nil, nil, fmt.Errorf("%w: bad public header length %d", ErrMalformedHeader, magicBlock.PublicHeaderLength)
 	}
 
 	publicHeaderObfuscatedBytes := make([]byte, magicBlock.PublicHeaderLength)
 	if _, err := io.ReadFull(r, publicHeaderObfuscatedBytes); err != nil {
-		return nil, nil, err
+		return nil, nil, truncatedOr(err, "reading public header")
 	}
 	publicHeaderBytes, err := p.Obfuscator.Unobfuscate(publicHeaderObfuscatedBytes, emptyPassword)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Unable to unobfuscate public header: %v", err)
+		return nil, nil, fmt.Errorf("%w: unable to unobfuscate public header: %v", ErrMalformedHeader, err)
 	}
 	publicHeader := pb.PublicHeader{}
 	if err := proto.Unmarshal(publicHeaderBytes, &publicHeader); err != nil {
-		return nil, nil, fmt.Errorf("Unable to parse public header: %v", err)
+		return nil, nil, fmt.Errorf("%w: unable to parse public header: %v", ErrMalformedHeader, err)
+	}
+	if chunkId != "" && publicHeader.ChunkId != chunkId {
+		return nil, nil, fmt.Errorf("%w: %q", ErrWrongChunk, publicHeader.ChunkId)
 	}
-	if publicHeader.PrivateHeaderLength <= 0 {
-		return nil, nil, fmt.Errorf("Public header had bad private header length: %d", publicHeader.PrivateHeaderLength)
+	if publicHeader.PrivateHeaderLength <= 0 || publicHeader.PrivateHeaderLength > MaxPrivateHeaderLength {
+		return nil, nil, fmt.Errorf("%w: bad private header length %d", ErrMalformedHeader, publicHeader.PrivateHeaderLength)
 	}
 
 	privateHeaderEncryptedBytes := make([]byte, publicHeader.PrivateHeaderLength)
 	if _, err := io.ReadFull(r, privateHeaderEncryptedBytes); err != nil {
-		return nil, nil, err
+		return nil, nil, truncatedOr(err, "reading private header")
 	}
-	privateHeaderPlaintextBytes, err := p.Encrypter.Decrypt(privateHeaderEncryptedBytes, nil)
+	associatedData := headerAssociatedData(magicBlock.ProtocolVersion, magicBlockObfuscatedBytes, publicHeaderObfuscatedBytes)
+	privateHeaderPlaintextBytes, err := p.Encrypter.Decrypt(privateHeaderEncryptedBytes, associatedData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to decrypt private header: %v", err)
+		return nil, nil, fmt.Errorf("%w: %v", ErrHeaderDecrypt, err)
 	}
 	privateHeader := pb.PrivateHeader{}
 	if err := proto.Unmarshal(privateHeaderPlaintextBytes, &privateHeader); err != nil {
-		return nil, nil, fmt.Errorf("Error parsing private header: %v", err)
+		return nil, nil, fmt.Errorf("%w: unable to parse private header: %v", ErrMalformedHeader, err)
+	}
+	if privateHeader.PlaintextLength < 0 {
+		return nil, nil, fmt.Errorf("%w: bad plaintext length %d", ErrMalformedHeader, privateHeader.PlaintextLength)
 	}
 
-	keysetReader := keyset.NewBinaryReader(bytes.NewBuffer(privateHeader.ChunkSpecificEncryptionKey))
-	kh, err := insecurecleartextkeyset.Read(keysetReader)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Error loading chunk-specific encryption keys: %v", err)
+	return &pb.Header{
+		Magic:   &magicBlock,
+		Public:  &publicHeader,
+		Private: &privateHeader,
+	}, associatedData, nil
+}
+
+// verifyingReader passes on the plaintext of a chunk, and checks its
+// length, chunk ID and any plaintext hashes in the private header when the
+// end is reached.
+type verifyingReader struct {
+	r      io.Reader
+	header *pb.Header
+	hasher *deduhash.Writer
+	hashes *plainhashes.Writer
+	err    error
+}
+
+func (v *verifyingReader) Read(buf []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
 	}
 
-	chunkSpecificCrypter, err := aead.New(kh)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Error loading chunk-specific encryption keys: %v", err)
+	n, err := v.r.Read(buf)
+	v.hasher.Write(buf[:n])
+	v.hashes.Write(buf[:n])
+
+	if v.hasher.Size() > v.header.Private.PlaintextLength {
+		v.err = fmt.Errorf("%w: plaintext is longer than the expected %d bytes", ErrMalformedPayload, v.header.Private.PlaintextLength)
+		return 0, v.err
 	}
 
-	rest, err := ioutil.ReadAll(r)
-	if err != nil {
-		return nil, nil, err
+	if err == io.EOF {
+		v.err = v.verify()
+		return n, v.err
 	}
-	plaintext, err := chunkSpecificCrypter.Decrypt(rest, nil)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Error decrypting data: %v", err)
+		v.err = err
+		return n, v.err
 	}
+	return n, nil
+}
 
-	header := &pb.Header{
-		Magic:   &magicBlock,
-		Public:  &publicHeader,
-		Private: &privateHeader,
+func (v *verifyingReader) verify() error {
+	size := v.hasher.Size()
+	if size != v.header.Private.PlaintextLength {
+		return fmt.Errorf("%w: plaintext has length %d (wanted %d)", ErrMalformedPayload, size, v.header.Private.PlaintextLength)
 	}
-
-	if len(plaintext) > 0 {
-		ok, err := p.Hasher.VerifyHash(bytes.NewReader(plaintext), int64(len(plaintext)), header.Public.ChunkId)
-		if !ok || err != nil {
-			return nil, nil, fmt.Errorf("Content chunk ID (%q) does not match decrypted data of %d bytes (%v)", header.Public.ChunkId, len(plaintext), err)
+	if vc := v.header.Private.VirtualChunk; vc != nil {
+		// The content of a virtual chunk is verified as it is reassembled,
+		// against the ID it describes, which must be the chunk's own.
+		if vc.ChunkId != v.header.Public.ChunkId {
+			return fmt.Errorf("%w: describes virtual chunk %q", ErrWrongChunk, vc.ChunkId)
 		}
+		return io.EOF
 	}
 
-	return plaintext, header, nil
+	computedHash, err := v.hasher.Sum()
+	if err != nil {
+		return err
+	}
+	if computedHash != v.header.Public.ChunkId {
+		return fmt.Errorf("%w: chunk ID is %q for %d bytes", ErrHashMismatch, computedHash, size)
+	}
+	if err := plainhashes.Verify(v.header.Private.PlaintextHashes, v.hashes.Hashes()); err != nil {
+		return fmt.Errorf("%w: %w", ErrHashMismatch, err)
+	}
+	return io.EOF
+}
+
+// decryptingReader labels errors from the streaming decrypter.
+type decryptingReader struct {
+	r io.Reader
+}
+
+func (d *decryptingReader) Read(buf []byte) (int, error) {
+	n, err := d.r.Read(buf)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", ErrPayloadDecrypt, err)
+	}
+	return n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(buf []byte) (int, error) {
+	n, err := c.w.Write(buf)
+	c.n += int64(n)
+	return n, err
 }
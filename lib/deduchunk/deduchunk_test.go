@@ -0,0 +1,341 @@
+package deduchunk_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/internal/chunktest"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+func newTestPacker(t *testing.T, version int32, compression pb.Compression, padding pb.Padding) *deduchunk.Packer {
+	t.Helper()
+
+	p := chunktest.NewPacker(t)
+	p.Compression = compression
+	p.Padding = padding
+	p.ProtocolVersion = version
+	return p
+}
+
+func hashOf(t *testing.T, p *deduchunk.Packer, data []byte) string {
+	t.Helper()
+	h, err := p.Hasher.ComputeHash(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestUnpackRejectsRenamedVirtualChunk(t *testing.T) {
+	for _, version := range []int32{deduchunk.ProtocolVersion1, deduchunk.ProtocolVersion2, deduchunk.ProtocolVersion3} {
+		p := chunktest.NewPacker(t)
+		p.ProtocolVersion = version
+
+		victim := hashOf(t, p, []byte("the file that was asked for"))
+		vc := &pb.VirtualChunk{
+			ChunkId:     hashOf(t, p, []byte("some other file")),
+			TotalLength: 15,
+			Chunk:       []*pb.ChunkReference{{Hash: hashOf(t, p, []byte("some other file")), Length: 15}},
+		}
+		packed, err := p.Pack(nil, &deduchunk.ExtraData{VirtualChunk: vc})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := p.Unpack(packed, vc.ChunkId); err != nil {
+			t.Errorf("version %d: Unpack(original) = %v", version, err)
+		}
+
+		// Version 3 binds the public header, so the renamed chunk does
+		// not even decrypt.
+		want := deduchunk.ErrWrongChunk
+		if version == deduchunk.ProtocolVersion3 {
+			want = deduchunk.ErrHeaderDecrypt
+		}
+		renamed := chunktest.RenameChunk(t, p, packed, victim)
+		if _, _, err := p.Unpack(renamed, victim); !errors.Is(err, want) {
+			t.Errorf("version %d: Unpack(renamed) = %v, want %v", version, err, want)
+		}
+	}
+}
+
+func TestUnpackRejectsRenamedEmptyChunk(t *testing.T) {
+	for _, version := range []int32{deduchunk.ProtocolVersion1, deduchunk.ProtocolVersion2, deduchunk.ProtocolVersion3} {
+		p := chunktest.NewPacker(t)
+		p.ProtocolVersion = version
+
+		packed, err := p.Pack(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := p.Unpack(packed, hashOf(t, p, nil)); err != nil {
+			t.Errorf("version %d: Unpack(original) = %v", version, err)
+		}
+
+		victim := hashOf(t, p, []byte("some file"))
+		renamed := chunktest.RenameChunk(t, p, packed, victim)
+		if _, _, err := p.Unpack(renamed, victim); err == nil {
+			t.Errorf("version %d: Unpack(renamed) succeeded", version)
+		}
+	}
+}
+
+var (
+	testVersions     = []int32{deduchunk.ProtocolVersion1, deduchunk.ProtocolVersion2, deduchunk.ProtocolVersion3}
+	testCompressions = []pb.Compression{pb.Compression_COMPRESSION_NONE, pb.Compression_COMPRESSION_DEFLATE}
+	testPaddings     = []pb.Padding{pb.Padding_PADDING_NONE, pb.Padding_PADDING_PADME, pb.Padding_PADDING_POWER_OF_TWO}
+)
+
+// testPlaintexts returns plaintexts covering the empty chunk, short and
+// compressible chunks, and incompressible chunks spanning several
+// streaming segments.
+func testPlaintexts() map[string][]byte {
+	random := make([]byte, 2*1024*1024+123)
+	rand.New(rand.NewSource(1)).Read(random)
+	return map[string][]byte{
+		"empty":          nil,
+		"short":          []byte("x"),
+		"compressible":   bytes.Repeat([]byte("all work and no play makes jack a dull boy\n"), 50000),
+		"incompressible": random,
+	}
+}
+
+// unpackStreamAll unpacks a chunk with UnpackStream and reads all of it.
+func unpackStreamAll(p *deduchunk.Packer, packed []byte, chunkId string) ([]byte, *pb.Header, error) {
+	r, header, err := p.UnpackStream(bytes.NewReader(packed), chunkId)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, header, nil
+}
+
+// checkRejected checks that err is a *Error reporting a failed check.
+func checkRejected(t *testing.T, desc string, err error) {
+	t.Helper()
+
+	var chunkErr *deduchunk.Error
+	if err == nil {
+		t.Errorf("%s: succeeded", desc)
+	} else if !errors.As(err, &chunkErr) || chunkErr.Check() == nil {
+		t.Errorf("%s: error %v does not report a failed check", desc, err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	plaintexts := testPlaintexts()
+
+	for _, version := range testVersions {
+		for _, compression := range testCompressions {
+			for _, padding := range testPaddings {
+				p := newTestPacker(t, version, compression, padding)
+
+				for name, plaintext := range plaintexts {
+					desc := fmt.Sprintf("version %d, %v, %v, %s", version, compression, padding, name)
+					chunkId := hashOf(t, p, plaintext)
+
+					metadata := &pb.ChunkMetadata{SuggestedFilename: "file.txt", Mode: 0640}
+					packed, err := p.Pack(plaintext, &deduchunk.ExtraData{Metadata: metadata})
+					if err != nil {
+						t.Fatalf("%s: Pack() = %v", desc, err)
+					}
+
+					for _, unpack := range []struct {
+						name string
+						f    func(*deduchunk.Packer, []byte, string) ([]byte, *pb.Header, error)
+					}{
+						{"Unpack", (*deduchunk.Packer).Unpack},
+						{"UnpackStream", unpackStreamAll},
+					} {
+						got, header, err := unpack.f(p, packed, chunkId)
+						if err != nil {
+							t.Errorf("%s: %s() = %v", desc, unpack.name, err)
+							continue
+						}
+						if !bytes.Equal(got, plaintext) {
+							t.Errorf("%s: %s() returned %d bytes, want %d", desc, unpack.name, len(got), len(plaintext))
+						}
+						if header.Magic.ProtocolVersion != version || header.Public.ChunkId != chunkId || header.Private.Padding != padding {
+							t.Errorf("%s: %s() returned header %v", desc, unpack.name, header)
+						}
+						if !proto.Equal(header.Private.OptionalMetadata, metadata) {
+							t.Errorf("%s: %s() returned metadata %v, want %v", desc, unpack.name, header.Private.OptionalMetadata, metadata)
+						}
+						wantCompression := pb.Compression_COMPRESSION_NONE
+						if name == "compressible" {
+							wantCompression = compression
+						}
+						if header.Private.Compression != wantCompression {
+							t.Errorf("%s: %s() returned compression %v, want %v", desc, unpack.name, header.Private.Compression, wantCompression)
+						}
+					}
+
+					if name == "compressible" && compression != pb.Compression_COMPRESSION_NONE && len(packed) > len(plaintext)/10 {
+						t.Errorf("%s: packed to %d bytes", desc, len(packed))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPaddingHidesLength(t *testing.T) {
+	for _, version := range testVersions {
+		p := newTestPacker(t, version, pb.Compression_COMPRESSION_NONE, pb.Padding_PADDING_POWER_OF_TWO)
+
+		var sizes []int
+		for _, length := range []int{3000, 3500, 4000} {
+			packed, err := p.Pack(bytes.Repeat([]byte{'a'}, length), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, err := p.InspectHeader(packed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sizes = append(sizes, len(packed)-int(deduchunk.HeaderSize(header)))
+		}
+		if sizes[0] != sizes[1] || sizes[1] != sizes[2] {
+			t.Errorf("version %d: payloads padded to different sizes %v", version, sizes)
+		}
+	}
+}
+
+func TestUnpackRejectsDamage(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789"), 150000)
+
+	for _, version := range testVersions {
+		for _, compression := range testCompressions {
+			for _, padding := range testPaddings {
+				p := newTestPacker(t, version, compression, padding)
+				chunkId := hashOf(t, p, plaintext)
+
+				packed, err := p.Pack(plaintext, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				header, err := p.InspectHeader(packed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				headerSize := int(deduchunk.HeaderSize(header))
+
+				damaged := map[string][]byte{}
+				for name, offset := range map[string]int{
+					"private header":   headerSize - 1,
+					"start of payload": headerSize + 40,
+					"end of payload":   len(packed) - 1,
+				} {
+					flipped := append([]byte(nil), packed...)
+					flipped[offset] ^= 1
+					damaged["flipped byte in "+name] = flipped
+				}
+				damaged["truncated payload"] = packed[:len(packed)-1]
+				damaged["truncated to headers"] = packed[:headerSize]
+				damaged["truncated header"] = packed[:headerSize-1]
+				damaged["trailing data"] = append(append([]byte(nil), packed...), 0)
+
+				for name, data := range damaged {
+					desc := fmt.Sprintf("version %d, %v, %v, %s", version, compression, padding, name)
+					_, _, err := p.Unpack(data, chunkId)
+					checkRejected(t, desc+": Unpack()", err)
+					_, _, err = unpackStreamAll(p, data, chunkId)
+					checkRejected(t, desc+": UnpackStream()", err)
+				}
+
+				wrongId := hashOf(t, p, []byte("something else"))
+				_, _, err = p.Unpack(packed, wrongId)
+				if !errors.Is(err, deduchunk.ErrWrongChunk) {
+					t.Errorf("version %d: Unpack() under another ID = %v, want deduchunk.ErrWrongChunk", version, err)
+				}
+			}
+		}
+	}
+}
+
+func TestPackStreamRoundTrip(t *testing.T) {
+	plaintexts := testPlaintexts()
+
+	for _, version := range testVersions {
+		for _, compression := range testCompressions {
+			for _, padding := range testPaddings {
+				p := newTestPacker(t, version, compression, padding)
+
+				wantVersion := version
+				if wantVersion < deduchunk.ProtocolVersion2 {
+					wantVersion = deduchunk.ProtocolVersion2
+				}
+
+				for name, plaintext := range plaintexts {
+					desc := fmt.Sprintf("version %d, %v, %v, %s", version, compression, padding, name)
+					chunkId := hashOf(t, p, plaintext)
+
+					metadata := &pb.ChunkMetadata{SuggestedFilename: "file.txt", Mode: 0640}
+					buf := bytes.NewBuffer(nil)
+					if err := p.PackStream(buf, bytes.NewReader(plaintext), chunkId, int64(len(plaintext)), &deduchunk.ExtraData{Metadata: metadata}); err != nil {
+						t.Fatalf("%s: PackStream() = %v", desc, err)
+					}
+					packed := buf.Bytes()
+
+					for _, unpack := range []struct {
+						name string
+						f    func(*deduchunk.Packer, []byte, string) ([]byte, *pb.Header, error)
+					}{
+						{"Unpack", (*deduchunk.Packer).Unpack},
+						{"UnpackStream", unpackStreamAll},
+					} {
+						got, header, err := unpack.f(p, packed, chunkId)
+						if err != nil {
+							t.Errorf("%s: %s() = %v", desc, unpack.name, err)
+							continue
+						}
+						if !bytes.Equal(got, plaintext) {
+							t.Errorf("%s: %s() returned %d bytes, want %d", desc, unpack.name, len(got), len(plaintext))
+						}
+						if header.Magic.ProtocolVersion != wantVersion || header.Public.ChunkId != chunkId || header.Private.Padding != padding {
+							t.Errorf("%s: %s() returned header %v", desc, unpack.name, header)
+						}
+						if !proto.Equal(header.Private.OptionalMetadata, metadata) {
+							t.Errorf("%s: %s() returned metadata %v, want %v", desc, unpack.name, header.Private.OptionalMetadata, metadata)
+						}
+						wantCompression := compression
+						if len(plaintext) == 0 {
+							wantCompression = pb.Compression_COMPRESSION_NONE
+						}
+						if header.Private.Compression != wantCompression {
+							t.Errorf("%s: %s() returned compression %v, want %v", desc, unpack.name, header.Private.Compression, wantCompression)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPackStreamRejectsWrongLength(t *testing.T) {
+	plaintext := []byte("some plaintext")
+
+	for _, compression := range testCompressions {
+		p := newTestPacker(t, deduchunk.ProtocolVersion3, compression, pb.Padding_PADDING_NONE)
+		chunkId := hashOf(t, p, plaintext)
+
+		for _, length := range []int64{int64(len(plaintext)) - 1, int64(len(plaintext)) + 1} {
+			err := p.PackStream(ioutil.Discard, bytes.NewReader(plaintext), chunkId, length, nil)
+			if err == nil {
+				t.Errorf("%v: PackStream() of %d bytes claimed as %d succeeded", compression, len(plaintext), length)
+			}
+		}
+	}
+}
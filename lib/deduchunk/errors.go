@@ -0,0 +1,100 @@
+package deduchunk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Reasons a packed chunk can be rejected. Errors from unpacking a chunk
+// are *Error values, which match one of these with errors.Is.
+var (
+	ErrTruncated          = errors.New("Truncated chunk")
+	ErrBadMagic           = errors.New("Bad magic block")
+	ErrUnsupportedVersion = errors.New("Unsupported protocol version")
+	ErrMalformedHeader    = errors.New("Malformed header")
+	ErrWrongChunk         = errors.New("Header has wrong chunk ID")
+	ErrHeaderDecrypt      = errors.New("Failed to decrypt private header")
+	ErrPayloadDecrypt     = errors.New("Failed to decrypt payload")
+	ErrMalformedPayload   = errors.New("Malformed payload")
+	ErrHashMismatch       = errors.New("Plaintext hash mismatch")
+)
+
+var chunkErrors = []error{
+	ErrTruncated,
+	ErrBadMagic,
+	ErrUnsupportedVersion,
+	ErrMalformedHeader,
+	ErrWrongChunk,
+	ErrHeaderDecrypt,
+	ErrPayloadDecrypt,
+	ErrMalformedPayload,
+	ErrHashMismatch,
+}
+
+// Maximum sizes of the headers of a packed chunk. Larger headers are
+// neither written nor read, so that a corrupt length field cannot make
+// us allocate without bound.
+const (
+	MaxPublicHeaderLength  = 4 * 1024
+	MaxPrivateHeaderLength = 16 * 1024 * 1024
+)
+
+// Error is an error unpacking the chunk with ID ChunkId (if known).
+type Error struct {
+	ChunkId string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.ChunkId == "" {
+		return fmt.Sprintf("Invalid chunk: %v", e.Err)
+	}
+	return fmt.Sprintf("Invalid chunk %q: %v", e.ChunkId, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Check returns the check the chunk failed, as one of the Err values
+// above, or nil if the failure was something else (such as an error
+// reading the chunk).
+func (e *Error) Check() error {
+	for _, check := range chunkErrors {
+		if errors.Is(e.Err, check) {
+			return check
+		}
+	}
+	return nil
+}
+
+func chunkError(chunkId string, err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return &Error{ChunkId: chunkId, Err: err}
+}
+
+// truncatedOr returns ErrTruncated if err means the data ended early, and
+// err itself otherwise.
+func truncatedOr(err error, format string, args ...interface{}) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: "+format, append([]interface{}{ErrTruncated}, args...)...)
+	}
+	return err
+}
+
+// errorReader wraps errors other than io.EOF from r in *Error.
+type errorReader struct {
+	r       io.Reader
+	chunkId string
+}
+
+func (e *errorReader) Read(buf []byte) (int, error) {
+	n, err := e.r.Read(buf)
+	return n, chunkError(e.chunkId, err)
+}
@@ -0,0 +1,55 @@
+package deduchunk
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/steinarvk/dedu/lib/deduhash"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// Limits on virtual chunks. A file with more chunks than fit in one
+// manifest is described by a tree of virtual chunks: the root references
+// intermediate virtual chunks (marked as virtual in their references),
+// which in turn reference data chunks or further intermediate ones.
+//
+// The depth of a virtual chunk is 1 if it references only data chunks,
+// and one more than the depth of the virtual chunks it references
+// otherwise. A depth of zero means 1, as in virtual chunks from before
+// nesting was supported.
+const (
+	MaxManifestEntries = 4096
+	MaxManifestDepth   = 4
+)
+
+func ManifestDepth(vc *pb.VirtualChunk) int32 {
+	if vc.Depth < 1 {
+		return 1
+	}
+	return vc.Depth
+}
+
+func checkManifestSize(vc *pb.VirtualChunk) error {
+	if len(vc.Chunk) > MaxManifestEntries {
+		return fmt.Errorf("Virtual chunk %q has %d references (maximum %d)", vc.ChunkId, len(vc.Chunk), MaxManifestEntries)
+	}
+	if depth := ManifestDepth(vc); depth > MaxManifestDepth {
+		return fmt.Errorf("Virtual chunk %q has depth %d (maximum %d)", vc.ChunkId, depth, MaxManifestDepth)
+	}
+	return nil
+}
+
+// IntermediateChunkId returns the chunk ID of an intermediate virtual
+// chunk. Unlike other chunk IDs it is not the hash of the content, which
+// would take another pass over the data to compute, but a hash of the
+// references (see Hasher.ComputeManifestHash). Its content is still
+// verified through the chunks it references, and the root's chunk ID.
+func IntermediateChunkId(h *deduhash.Hasher, vc *pb.VirtualChunk) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "depth %d\nlength %d\n", ManifestDepth(vc), vc.TotalLength)
+	for _, ref := range vc.Chunk {
+		fmt.Fprintf(buf, "%s %d %t\n", ref.Hash, ref.Length, ref.Virtual)
+	}
+	return h.ComputeManifestHash(buf.Bytes())
+}
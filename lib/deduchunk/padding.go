@@ -0,0 +1,134 @@
+package deduchunk
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// paddedLength returns the length a payload of the given length is padded
+// to before encryption.
+func paddedLength(padding pb.Padding, length int64) (int64, error) {
+	switch padding {
+	case pb.Padding_PADDING_NONE:
+		return length, nil
+
+	case pb.Padding_PADDING_PADME:
+		return padme(length), nil
+
+	case pb.Padding_PADDING_POWER_OF_TWO:
+		if length <= 1 {
+			return length, nil
+		}
+		return int64(1) << uint(bits.Len64(uint64(length-1))), nil
+
+	default:
+		return 0, fmt.Errorf("Unsupported padding scheme %v", padding)
+	}
+}
+
+// padme rounds length up as in the Padmé scheme of Nikitin et al.,
+// "Reducing Metadata Leakage from Encrypted Files and Communication with
+// PURBs". Only O(log log length) bits of the length are kept, at a cost
+// of at most 12% overhead.
+func padme(length int64) int64 {
+	if length < 2 {
+		return length
+	}
+	e := bits.Len64(uint64(length)) - 1
+	s := bits.Len64(uint64(e))
+	mask := int64(1)<<uint(e-s) - 1
+	return (length + mask) &^ mask
+}
+
+// padding returns the zeroes to append to a payload of the given length.
+func padding(scheme pb.Padding, length int64) ([]byte, error) {
+	padded, err := paddedLength(scheme, length)
+	if err != nil {
+		return nil, err
+	}
+	return make([]byte, padded-length), nil
+}
+
+// maxPayloadLength bounds the length of the payload holding plaintext of
+// the given length, before padding.
+func maxPayloadLength(compression pb.Compression, plaintextLength int64) int64 {
+	if compression == pb.Compression_COMPRESSION_NONE {
+		return plaintextLength
+	}
+	// Generous even for incompressible data, which DEFLATE stores in
+	// blocks with a few bytes of overhead each.
+	return plaintextLength + plaintextLength/1024 + 1024
+}
+
+// payloadReader reads the plaintext from the decrypted payload of a chunk,
+// which may be compressed and padded.
+//
+// Once all of the plaintext has been read, the rest of the payload is read
+// and discarded, so that all of it is authenticated. Payloads longer than
+// the private header allows for are rejected.
+type payloadReader struct {
+	payload   *io.LimitedReader
+	plaintext io.Reader
+	remaining int64
+	maxLength int64
+	done      bool
+}
+
+func newPayloadReader(privateHeader *pb.PrivateHeader, payload io.Reader) (*payloadReader, error) {
+	plaintextLength := privateHeader.PlaintextLength
+
+	maxLength, err := paddedLength(privateHeader.Padding, maxPayloadLength(privateHeader.Compression, plaintextLength))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHeader, err)
+	}
+
+	limited := &io.LimitedReader{R: payload, N: maxLength + 1}
+	plaintext, err := newDecompressingReader(privateHeader.Compression, limited)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHeader, err)
+	}
+
+	return &payloadReader{
+		payload:   limited,
+		plaintext: plaintext,
+		remaining: plaintextLength,
+		maxLength: maxLength,
+	}, nil
+}
+
+func (r *payloadReader) Read(buf []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+
+	var n int
+	if r.remaining > 0 {
+		if int64(len(buf)) > r.remaining {
+			buf = buf[:r.remaining]
+		}
+		var err error
+		n, err = r.plaintext.Read(buf)
+		r.remaining -= int64(n)
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if err == nil && r.remaining > 0 {
+			return n, nil
+		}
+		// Either all of the plaintext has been read, or it ended early,
+		// which the caller detects.
+	}
+
+	r.done = true
+	if _, err := io.Copy(ioutil.Discard, r.payload); err != nil {
+		return n, err
+	}
+	if r.payload.N == 0 {
+		return n, fmt.Errorf("%w: payload is longer than the maximum of %d bytes", ErrMalformedPayload, r.maxLength)
+	}
+	return n, io.EOF
+}
@@ -3,12 +3,16 @@ package deduhash
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"regexp"
 	"strings"
+
+	"golang.org/x/crypto/blake2b"
 )
 
 var (
@@ -17,8 +21,25 @@ var (
 
 var fixedSalt = []byte("dedu.hash.2")
 
+// manifestSalt separates manifest hashes from content hashes, so that no
+// content can have the same hash as a manifest.
+var manifestSalt = []byte("dedu.manifest.1")
+
+// Hash versions. Version 1 is HMAC-SHA256, truncated to 160 bits. Version
+// 2 is keyed BLAKE2b-256 in the tree mode described at treeHashV2, with
+// the full 256 bits kept.
+//
+// A Hasher computes hashes of one version, but verifies hashes of either.
+const (
+	Version1       = "1"
+	Version2       = "2"
+	DefaultVersion = Version1
+)
+
 type Hasher struct {
-	key []byte
+	key     []byte
+	version string
+	keyV2   []byte
 }
 
 type parsedHash struct {
@@ -31,9 +52,140 @@ const (
 	bufferSize = 10 * 1024
 )
 
-func (h *Hasher) computeHashV1(r io.Reader) (string, int64, error) {
+func (h *Hasher) newMACV1() hash.Hash {
 	mac := hmac.New(sha256.New, h.key)
 	mac.Write(fixedSalt)
+	return mac
+}
+
+// keyV2Salt derives the BLAKE2b key from the hashing key, which may be
+// longer than BLAKE2b allows.
+var keyV2Salt = []byte("dedu.hash.v2.key")
+
+// Domain separation bytes for version 2.
+const (
+	v2Leaf     = 0
+	v2Root     = 1
+	v2Length   = 2
+	v2Manifest = 3
+)
+
+// v2LeafSize is the size of the leaves of the version 2 tree.
+const v2LeafSize = 1024 * 1024
+
+func deriveKeyV2(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(keyV2Salt)
+	return mac.Sum(nil)
+}
+
+func (h *Hasher) newBLAKE2b(domain byte) hash.Hash {
+	b, err := blake2b.New256(h.keyV2)
+	if err != nil {
+		// Only possible with a key longer than 64 bytes.
+		panic(fmt.Sprintf("Sanity check failed: %v", err))
+	}
+	b.Write([]byte{domain})
+	return b
+}
+
+// treeHashV2 computes the version 2 content hash. The input is split into
+// leaves of v2LeafSize bytes (the last one possibly shorter; there are no
+// leaves for empty input), and leaf i is hashed as
+//
+//	BLAKE2b-256(k, 0x00 || uint64be(i) || leaf)
+//
+// The root, which is the content hash, is
+//
+//	BLAKE2b-256(k, 0x01 || leaf hashes || uint64be(leaves) || uint64be(length))
+//
+// Leaves are independent, so they may be hashed in parallel, or verified
+// individually given the leaf hashes.
+type treeHashV2 struct {
+	h        *Hasher
+	root     hash.Hash
+	leaf     hash.Hash
+	leafSize int
+	leaves   uint64
+	length   int64
+}
+
+func (h *Hasher) newTreeHashV2() *treeHashV2 {
+	return &treeHashV2{
+		h:    h,
+		root: h.newBLAKE2b(v2Root),
+	}
+}
+
+func (t *treeHashV2) Write(data []byte) (int, error) {
+	n := len(data)
+	for len(data) > 0 {
+		if t.leaf == nil {
+			t.leaf = t.h.newBLAKE2b(v2Leaf)
+			var index [8]byte
+			binary.BigEndian.PutUint64(index[:], t.leaves)
+			t.leaf.Write(index[:])
+		}
+
+		take := v2LeafSize - t.leafSize
+		if take > len(data) {
+			take = len(data)
+		}
+		t.leaf.Write(data[:take])
+		t.leafSize += take
+		t.length += int64(take)
+		data = data[take:]
+
+		if t.leafSize == v2LeafSize {
+			t.finishLeaf()
+		}
+	}
+	return n, nil
+}
+
+func (t *treeHashV2) finishLeaf() {
+	t.root.Write(t.leaf.Sum(nil))
+	t.leaf = nil
+	t.leafSize = 0
+	t.leaves++
+}
+
+// Sum finishes the hash; nothing more may be written afterwards.
+func (t *treeHashV2) Sum(b []byte) []byte {
+	if t.leaf != nil {
+		t.finishLeaf()
+	}
+	var trailer [16]byte
+	binary.BigEndian.PutUint64(trailer[:8], t.leaves)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(t.length))
+	t.root.Write(trailer[:])
+	return t.root.Sum(b)
+}
+
+// newContentHash returns the hash of the given version to compute the
+// main part of a content hash with. Its Sum may only be called once.
+func (h *Hasher) newContentHash(version string) (io.Writer, func() []byte, error) {
+	switch version {
+	case Version1:
+		mac := h.newMACV1()
+		return mac, func() []byte { return mac.Sum(nil) }, nil
+	case Version2:
+		t := h.newTreeHashV2()
+		return t, func() []byte { return t.Sum(nil) }, nil
+	default:
+		return nil, nil, fmt.Errorf("Unknown hash version %q", version)
+	}
+}
+
+func (h *Hasher) computeHashV1(r io.Reader) (string, int64, error) {
+	return h.computeContentHash(r, Version1)
+}
+
+func (h *Hasher) computeContentHash(r io.Reader, version string) (string, int64, error) {
+	mac, sum, err := h.newContentHash(version)
+	if err != nil {
+		return "", 0, err
+	}
 
 	buf := make([]byte, bufferSize)
 	var sz int64
@@ -50,7 +202,7 @@ func (h *Hasher) computeHashV1(r io.Reader) (string, int64, error) {
 			return "", 0, fmt.Errorf("Read error: %v", err)
 		}
 	}
-	checksum := mac.Sum(nil)
+	checksum := sum()
 	hexdigest := fmt.Sprintf("%x", checksum)
 	return hexdigest, sz, nil
 }
@@ -68,6 +220,24 @@ func (h *Hasher) computeLengthHashV1(n int64) (string, error) {
 	return rv[:lengthHashLength], nil
 }
 
+func (h *Hasher) computeLengthHashV2(n int64) string {
+	lengthHashLength := 3
+	b := h.newBLAKE2b(v2Length)
+	fmt.Fprintf(b, "%d", n)
+	return fmt.Sprintf("%x", b.Sum(nil))[:lengthHashLength]
+}
+
+func (h *Hasher) computeLengthHash(version string, n int64) (string, error) {
+	switch version {
+	case Version1:
+		return h.computeLengthHashV1(n)
+	case Version2:
+		return h.computeLengthHashV2(n), nil
+	default:
+		return "", fmt.Errorf("Unknown hash version %q", version)
+	}
+}
+
 func parseHash(h string) (*parsedHash, error) {
 	components := strings.Split(h, "-")
 	if len(components) == 0 {
@@ -83,6 +253,15 @@ func parseHash(h string) (*parsedHash, error) {
 			contentsHash: components[1] + components[3],
 			lengthHash:   components[2],
 		}, nil
+	case "2":
+		if len(components) != 4 {
+			return nil, fmt.Errorf("Wrong number of dashed components in hash: %q", h)
+		}
+		return &parsedHash{
+			hashVersion:  "2",
+			contentsHash: components[1] + components[3],
+			lengthHash:   components[2],
+		}, nil
 	default:
 		return nil, fmt.Errorf("Unknown hash kind %q", components[0])
 	}
@@ -101,6 +280,15 @@ func formatHashV1(mainhash, lhash string) (string, error) {
 	return fmt.Sprintf("1-%s-%s-%s", prefix, lhash, suffix), nil
 }
 
+func formatHashV2(mainhash, lhash string) (string, error) {
+	// As in version 1, but keeping all 256 bits.
+	halfLength := 32
+	if len(mainhash) != 2*halfLength {
+		return "", fmt.Errorf("Main hash %q has wrong length (wanted %d)", mainhash, 2*halfLength)
+	}
+	return fmt.Sprintf("2-%s-%s-%s", mainhash[:halfLength], lhash, mainhash[halfLength:]), nil
+}
+
 func (h *Hasher) ComputeFileHash(filename string) (string, error) {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -116,34 +304,127 @@ func (h *Hasher) ComputeFileHash(filename string) (string, error) {
 	return rv, nil
 }
 
+// ComputeManifestHash hashes a serialized manifest. The result has the
+// same form as a content hash, but is computed with a different salt (or
+// in version 2, a different domain byte).
+func (h *Hasher) ComputeManifestHash(manifest []byte) (string, error) {
+	var digest []byte
+	switch h.version {
+	case Version1:
+		mac := hmac.New(sha256.New, h.key)
+		mac.Write(manifestSalt)
+		mac.Write(manifest)
+		digest = mac.Sum(nil)
+	case Version2:
+		b := h.newBLAKE2b(v2Manifest)
+		b.Write(manifest)
+		digest = b.Sum(nil)
+	default:
+		return "", fmt.Errorf("Unknown hash version %q", h.version)
+	}
+	return h.finishHash(h.version, fmt.Sprintf("%x", digest), int64(len(manifest)))
+}
+
 func (h *Hasher) ComputeHash(r io.Reader) (string, error) {
-	digest, length, err := h.computeHashV1(r)
+	return h.computeHash(r, h.version)
+}
+
+func (h *Hasher) computeHash(r io.Reader, version string) (string, error) {
+	digest, length, err := h.computeContentHash(r, version)
 	if err != nil {
 		return "", fmt.Errorf("Failed to compute hash: %v", err)
 	}
 
-	ldigest, err := h.computeLengthHashV1(length)
+	return h.finishHash(version, digest, length)
+}
+
+func (h *Hasher) finishHash(version, digest string, length int64) (string, error) {
+	ldigest, err := h.computeLengthHash(version, length)
 	if err != nil {
 		return "", fmt.Errorf("Failed to compute length-hash: %v", err)
 	}
 
-	rv, err := formatHashV1(digest, ldigest)
+	var rv string
+	switch version {
+	case Version1:
+		rv, err = formatHashV1(digest, ldigest)
+	case Version2:
+		rv, err = formatHashV2(digest, ldigest)
+	default:
+		err = fmt.Errorf("Unknown hash version %q", version)
+	}
 	if err != nil {
 		return "", fmt.Errorf("Failed to format hash: %v", err)
 	}
 	return rv, nil
 }
 
+// Writer computes a hash incrementally over the data written to it, for
+// when the data is not available as a single io.Reader.
+type Writer struct {
+	hasher  *Hasher
+	version string
+	mac     io.Writer
+	sum     func() []byte
+	size    int64
+}
+
+func (h *Hasher) NewWriter() *Writer {
+	w, err := h.newWriter(h.version)
+	if err != nil {
+		panic(fmt.Sprintf("Sanity check failed: %v", err))
+	}
+	return w
+}
+
+// NewWriterFor returns a Writer computing hashes of the same version as
+// the given hash, for verifying it.
+func (h *Hasher) NewWriterFor(hash string) (*Writer, error) {
+	parsed, err := parseHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return h.newWriter(parsed.hashVersion)
+}
+
+func (h *Hasher) newWriter(version string) (*Writer, error) {
+	mac, sum, err := h.newContentHash(version)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		hasher:  h,
+		version: version,
+		mac:     mac,
+		sum:     sum,
+	}, nil
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	w.size += int64(len(data))
+	return w.mac.Write(data)
+}
+
+// Size returns the number of bytes written so far.
+func (w *Writer) Size() int64 {
+	return w.size
+}
+
+// Sum returns the hash of the data written. It gives the same result as
+// ComputeHash on the same data (with the Writer's version). Nothing more
+// may be written afterwards.
+func (w *Writer) Sum() (string, error) {
+	digest := fmt.Sprintf("%x", w.sum())
+	return w.hasher.finishHash(w.version, digest, w.size)
+}
+
 func (h *Hasher) VerifyHash(r io.Reader, size int64, hash string) (bool, error) {
 	parsed, err := parseHash(hash)
 	if err != nil {
 		return false, err
 	}
-	if parsed.hashVersion != "1" {
-		return false, fmt.Errorf("Unknown hash version %q", parsed.hashVersion)
-	}
 
-	lh, err := h.computeLengthHashV1(size)
+	lh, err := h.computeLengthHash(parsed.hashVersion, size)
 	if err != nil {
 		return false, err
 	}
@@ -151,7 +432,7 @@ func (h *Hasher) VerifyHash(r io.Reader, size int64, hash string) (bool, error)
 		return false, Mismatch
 	}
 
-	computedHash, err := h.ComputeHash(r)
+	computedHash, err := h.computeHash(r, parsed.hashVersion)
 	if err != nil {
 		return false, fmt.Errorf("Failed to verify hash: %v", err)
 	}
@@ -178,15 +459,59 @@ func (h *Hasher) sanityCheck() error {
 	return nil
 }
 
+// New returns a Hasher computing hashes of DefaultVersion.
 func New(key []byte) (*Hasher, error) {
-	rv := &Hasher{key: key}
+	return NewVersion(key, DefaultVersion)
+}
+
+func NewVersion(key []byte, version string) (*Hasher, error) {
+	if version != Version1 && version != Version2 {
+		return nil, fmt.Errorf("Unknown hash version %q", version)
+	}
+	rv := &Hasher{
+		key:     key,
+		version: version,
+		keyV2:   deriveKeyV2(key),
+	}
 	if err := rv.sanityCheck(); err != nil {
 		return nil, err
 	}
 	return rv, nil
 }
 
-var deduhashRE = regexp.MustCompile(`^1-[0-9a-f]{20}-[0-9a-f]{3}-[0-9a-f]{20}$`)
+// WithVersion returns a Hasher with the same key, computing hashes of the
+// given version.
+func (h *Hasher) WithVersion(version string) (*Hasher, error) {
+	return NewVersion(h.key, version)
+}
+
+// ForHash returns a Hasher with the same key, computing hashes of the same
+// version as the given hash.
+func (h *Hasher) ForHash(hash string) (*Hasher, error) {
+	parsed, err := parseHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.hashVersion == h.version {
+		return h, nil
+	}
+	return h.WithVersion(parsed.hashVersion)
+}
+
+func (h *Hasher) Version() string {
+	return h.version
+}
+
+// VersionOf returns the version of the given hash.
+func VersionOf(hash string) (string, error) {
+	parsed, err := parseHash(hash)
+	if err != nil {
+		return "", err
+	}
+	return parsed.hashVersion, nil
+}
+
+var deduhashRE = regexp.MustCompile(`^(1-[0-9a-f]{20}-[0-9a-f]{3}-[0-9a-f]{20}|2-[0-9a-f]{32}-[0-9a-f]{3}-[0-9a-f]{32})$`)
 
 func LooksLikeDeduhash(s string) bool {
 	return deduhashRE.MatchString(s)
@@ -0,0 +1,236 @@
+package deduhash
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var testKey = []byte("golden test key")
+
+// testData returns n bytes of a fixed pattern.
+func testData(n int) []byte {
+	rv := make([]byte, n)
+	for i := range rv {
+		rv[i] = byte(i % 251)
+	}
+	return rv
+}
+
+// Hashes of testData under testKey. These must never change: chunks are
+// stored under these IDs.
+var goldenHashes = []struct {
+	version string
+	length  int
+	hash    string
+}{
+	{Version1, 0, "1-77e70b39d20811f8206c-35f-8d2d86356a0d05af114a"},
+	{Version1, 3, "1-d1d222d46714156f3ee3-ef3-c1235569b361c5972aed"},
+	{Version1, v2LeafSize - 1, "1-eb0063393f68eaa585f5-9d7-ad7636683ad41b664ca4"},
+	{Version1, v2LeafSize, "1-f8f523c2b3c5c864442c-01b-9daee350c324b267aff3"},
+	{Version1, v2LeafSize + 1, "1-633f4c2daece78ed46cc-a5a-372125138668ad029770"},
+	{Version1, 3*v2LeafSize + 17, "1-6d7fb217272f91f049c8-942-4de5f50e0e433295a355"},
+	{Version2, 0, "2-81c19c214d66f612332e5d79a913178c-5a8-16f87e97e90eaae8990b517add322f70"},
+	{Version2, 3, "2-3bd6382470c198d80e6369eb8b3c61cf-546-e4abfe558013d0859c6a7d91005f4db2"},
+	{Version2, v2LeafSize - 1, "2-a886649fec4243a1e7e6696b22abac90-48e-9a345c801503c27fd1d743ac8d795b17"},
+	{Version2, v2LeafSize, "2-78a03e7750fe419202a3be652cae7122-153-7084c16efc74421b80eb3993a93f9798"},
+	{Version2, v2LeafSize + 1, "2-ca3901a192f75e633124ec9b1ff68e0d-857-746b095414c26ddfd0a33fd984140df2"},
+	{Version2, 3*v2LeafSize + 17, "2-034e08dd87460d487fa13ca241cfa0a9-ff0-1c0ac201c2c9b88d9df8334b9042392c"},
+}
+
+var goldenManifestHashes = map[string]string{
+	Version1: "1-7807af570ec7d083af4c-fc4-08d722658a16230a5c6f",
+	Version2: "2-396bd45e07856c37bfae7cb778fb674d-e0d-00a8541e6d82f54265128a3d860a5796",
+}
+
+func newTestHasher(t *testing.T, version string) *Hasher {
+	t.Helper()
+	h, err := NewVersion(testKey, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestGoldenHashes(t *testing.T) {
+	for _, tc := range goldenHashes {
+		h := newTestHasher(t, tc.version)
+		data := testData(tc.length)
+
+		got, err := h.ComputeHash(bytes.NewReader(data))
+		if err != nil || got != tc.hash {
+			t.Errorf("version %s: ComputeHash(%d bytes) = %q, %v; want %q", tc.version, tc.length, got, err, tc.hash)
+		}
+
+		// Reads of odd sizes must not change the result.
+		got, err = h.ComputeHash(iotest.OneByteReader(bytes.NewReader(data[:tc.length%4096])))
+		if want, _ := h.ComputeHash(bytes.NewReader(data[:tc.length%4096])); err != nil || got != want {
+			t.Errorf("version %s: ComputeHash(one byte at a time) = %q, %v; want %q", tc.version, got, err, want)
+		}
+
+		w := h.NewWriter()
+		for rest := data; len(rest) > 0; {
+			n := 7777
+			if n > len(rest) {
+				n = len(rest)
+			}
+			w.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if got, err := w.Sum(); err != nil || got != tc.hash || w.Size() != int64(tc.length) {
+			t.Errorf("version %s: Writer.Sum() of %d bytes = %q, %v (size %d); want %q", tc.version, tc.length, got, err, w.Size(), tc.hash)
+		}
+
+		if !LooksLikeDeduhash(tc.hash) {
+			t.Errorf("LooksLikeDeduhash(%q) = false", tc.hash)
+		}
+		if v, err := VersionOf(tc.hash); err != nil || v != tc.version {
+			t.Errorf("VersionOf(%q) = %q, %v", tc.hash, v, err)
+		}
+	}
+}
+
+func TestGoldenManifestHashes(t *testing.T) {
+	for version, want := range goldenManifestHashes {
+		h := newTestHasher(t, version)
+		got, err := h.ComputeManifestHash([]byte("manifest"))
+		if err != nil || got != want {
+			t.Errorf("version %s: ComputeManifestHash() = %q, %v; want %q", version, got, err, want)
+		}
+
+		content, err := h.ComputeHash(bytes.NewReader([]byte("manifest")))
+		if err != nil || content == got {
+			t.Errorf("version %s: manifest hash equals content hash %q", version, content)
+		}
+	}
+}
+
+// TestSpecification recomputes hashes from their definitions, using only
+// the underlying primitives.
+func TestSpecification(t *testing.T) {
+	data := testData(3*v2LeafSize + 17)
+
+	mac := func(parts ...string) string {
+		m := hmac.New(sha256.New, testKey)
+		m.Write([]byte("dedu.hash.2"))
+		for _, part := range parts {
+			m.Write([]byte(part))
+		}
+		return fmt.Sprintf("%x", m.Sum(nil))
+	}
+	main := mac(string(data))
+	wantV1 := fmt.Sprintf("1-%s-%s-%s", main[:20], mac(fmt.Sprint(len(data)))[:3], main[20:40])
+
+	keyMAC := hmac.New(sha256.New, testKey)
+	keyMAC.Write([]byte("dedu.hash.v2.key"))
+	key := keyMAC.Sum(nil)
+	blake := func(domain byte, parts ...[]byte) []byte {
+		b, err := blake2b.New256(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write([]byte{domain})
+		for _, part := range parts {
+			b.Write(part)
+		}
+		return b.Sum(nil)
+	}
+	uint64be := func(n int) []byte {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(n))
+		return buf[:]
+	}
+	var rootInput []byte
+	leaves := 0
+	for offset := 0; offset < len(data); offset += v2LeafSize {
+		end := offset + v2LeafSize
+		if end > len(data) {
+			end = len(data)
+		}
+		rootInput = append(rootInput, blake(0, uint64be(leaves), data[offset:end])...)
+		leaves++
+	}
+	root := fmt.Sprintf("%x", blake(1, rootInput, uint64be(leaves), uint64be(len(data))))
+	lengthHash := fmt.Sprintf("%x", blake(2, []byte(fmt.Sprint(len(data)))))
+	wantV2 := fmt.Sprintf("2-%s-%s-%s", root[:32], lengthHash[:3], root[32:])
+
+	for version, want := range map[string]string{Version1: wantV1, Version2: wantV2} {
+		got, err := newTestHasher(t, version).ComputeHash(bytes.NewReader(data))
+		if err != nil || got != want {
+			t.Errorf("version %s: ComputeHash() = %q, %v; want %q", version, got, err, want)
+		}
+	}
+}
+
+func TestVerifyAcrossVersions(t *testing.T) {
+	data := testData(v2LeafSize + 1)
+	other := append(testData(v2LeafSize), 0)
+
+	for _, hasherVersion := range []string{Version1, Version2} {
+		h := newTestHasher(t, hasherVersion)
+
+		for _, tc := range goldenHashes {
+			if tc.length != len(data) {
+				continue
+			}
+
+			if ok, err := h.VerifyHash(bytes.NewReader(data), int64(len(data)), tc.hash); !ok || err != nil {
+				t.Errorf("version %s hasher: VerifyHash(%q) = %v, %v", hasherVersion, tc.hash, ok, err)
+			}
+			if ok, err := h.VerifyHash(bytes.NewReader(other), int64(len(other)), tc.hash); ok || err != Mismatch {
+				t.Errorf("version %s hasher: VerifyHash(other data, %q) = %v, %v; want Mismatch", hasherVersion, tc.hash, ok, err)
+			}
+			if ok, err := h.VerifyHash(bytes.NewReader(data[1:]), int64(len(data)-1), tc.hash); ok || err != Mismatch {
+				t.Errorf("version %s hasher: VerifyHash(short data, %q) = %v, %v; want Mismatch", hasherVersion, tc.hash, ok, err)
+			}
+
+			w, err := h.NewWriterFor(tc.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(w, bytes.NewReader(data))
+			if got, err := w.Sum(); err != nil || got != tc.hash {
+				t.Errorf("version %s hasher: NewWriterFor(%q).Sum() = %q, %v", hasherVersion, tc.hash, got, err)
+			}
+
+			forHash, err := h.ForHash(tc.hash)
+			if err != nil || forHash.Version() != tc.version {
+				t.Errorf("version %s hasher: ForHash(%q) has version %q, %v", hasherVersion, tc.hash, forHash.Version(), err)
+			}
+		}
+
+		if h.NewWriter().version != hasherVersion {
+			t.Errorf("version %s hasher: NewWriter() computes version %q", hasherVersion, h.NewWriter().version)
+		}
+	}
+}
+
+func TestRejectsUnknownVersions(t *testing.T) {
+	if _, err := NewVersion(testKey, "3"); err == nil {
+		t.Errorf("NewVersion(\"3\") succeeded")
+	}
+
+	h := newTestHasher(t, DefaultVersion)
+	for _, hash := range []string{
+		"3-81c19c214d66f612332e5d79a913178c-5a8-16f87e97e90eaae8990b517add322f70",
+		"2-81c19c214d66f612332e5d79a913178c-5a8",
+		"",
+	} {
+		if _, err := h.NewWriterFor(hash); err == nil {
+			t.Errorf("NewWriterFor(%q) succeeded", hash)
+		}
+		if _, err := h.VerifyHash(bytes.NewReader(nil), 0, hash); err == nil || err == Mismatch {
+			t.Errorf("VerifyHash(%q) = %v, want a parse error", hash, err)
+		}
+		if LooksLikeDeduhash(hash) {
+			t.Errorf("LooksLikeDeduhash(%q) = true", hash)
+		}
+	}
+}
@@ -26,6 +26,9 @@ type Dedu struct {
 	Packer      *deduchunk.Packer
 	Obfuscator  *obfuscate.Obfuscator
 	PcloudCreds *pb.PcloudCredentials
+	LocalCreds  *pb.LocalStorageCredentials
+	S3Creds     *pb.S3Credentials
+	WebdavCreds *pb.WebdavCredentials
 	Config      *pb.DeduConfig
 	Quasihasher quasihash.Key
 }
@@ -70,7 +73,12 @@ func LoadFromFile(secretsFilename, configFilename string) (*Dedu, error) {
 		return nil, fmt.Errorf("No hashing_key set")
 	}
 
-	hasher, err := deduhash.New(secretsConfig.HashingKey)
+	hashVersion := secretsConfig.Config.GetHashVersion()
+	if hashVersion == "" {
+		hashVersion = deduhash.DefaultVersion
+	}
+
+	hasher, err := deduhash.NewVersion(secretsConfig.HashingKey, hashVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -97,24 +105,75 @@ func LoadFromFile(secretsFilename, configFilename string) (*Dedu, error) {
 		return nil, fmt.Errorf("No known kind of encryption_keys set")
 	}
 
-	pc := secretsConfig.GetStorageCreds().Pcloud
-	if pc.Username == "" || pc.Password == "" {
-		return nil, fmt.Errorf("No storage_creds.pcloud provided: no known storage")
+	if pc := secretsConfig.GetStorageCreds().GetPcloud(); pc != nil {
+		hasPassword := pc.Username != "" && pc.Password != ""
+		if !hasPassword && pc.AuthToken == "" {
+			return nil, fmt.Errorf("Incomplete storage_creds.pcloud provided: need username and password, or auth_token")
+		}
+		rv.PcloudCreds = pc
+	}
+
+	if lc := secretsConfig.GetStorageCreds().GetLocal(); lc != nil {
+		if lc.RootDirectory == "" {
+			return nil, fmt.Errorf("Incomplete storage_creds.local provided: need root_directory")
+		}
+		rv.LocalCreds = lc
 	}
 
-	rv.PcloudCreds = pc
+	if sc := secretsConfig.GetStorageCreds().GetS3(); sc != nil {
+		if sc.Endpoint == "" || sc.Bucket == "" || sc.AccessKeyId == "" || sc.SecretAccessKey == "" {
+			return nil, fmt.Errorf("Incomplete storage_creds.s3 provided: need endpoint, bucket, access_key_id and secret_access_key")
+		}
+		if sc.Region == "" {
+			sc.Region = "us-east-1"
+		}
+		rv.S3Creds = sc
+	}
+
+	if wc := secretsConfig.GetStorageCreds().GetWebdav(); wc != nil {
+		if wc.BaseUrl == "" {
+			return nil, fmt.Errorf("Incomplete storage_creds.webdav provided: need base_url")
+		}
+		rv.WebdavCreds = wc
+	}
 
 	rv.Chunker = &chunker.Chunker{
 		Hasher:    rv.Hasher,
 		ChunkSize: rv.Config.ChunkSize,
+		Workers:   int(rv.Config.GetUpload().GetChunkWorkers()),
+	}
+
+	if cdc := rv.Config.GetContentDefinedChunking(); cdc != nil {
+		chunkSize := rv.Config.ChunkSize
+		if chunkSize == 0 {
+			chunkSize = chunker.DefaultChunkSize
+		}
+		params := chunker.CDCParams{
+			MinSize: cdc.MinSize,
+			AvgSize: cdc.AvgSize,
+			MaxSize: cdc.MaxSize,
+		}.WithDefaults(chunkSize)
+		if err := params.Validate(); err != nil {
+			return nil, err
+		}
+		rv.Chunker.CDC = &params
 	}
 
 	rv.Obfuscator = obfuscate.New()
 
+	switch rv.Config.ProtocolVersion {
+	case 0, deduchunk.ProtocolVersion1, deduchunk.ProtocolVersion2, deduchunk.ProtocolVersion3:
+	default:
+		return nil, fmt.Errorf("Unsupported protocol_version %d", rv.Config.ProtocolVersion)
+	}
+
 	rv.Packer = &deduchunk.Packer{
-		Encrypter:  rv.Encrypter,
-		Hasher:     rv.Hasher,
-		Obfuscator: rv.Obfuscator,
+		Encrypter:       rv.Encrypter,
+		Hasher:          rv.Hasher,
+		Obfuscator:      rv.Obfuscator,
+		Compression:     rv.Config.Compression,
+		Padding:         rv.Config.Padding,
+		ProtocolVersion: rv.Config.ProtocolVersion,
 	}
 
 	return rv, nil
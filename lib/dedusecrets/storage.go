@@ -0,0 +1,81 @@
+package dedusecrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/steinarvk/dedu/lib/blobstore"
+	"github.com/steinarvk/dedu/lib/httpclient"
+	"github.com/steinarvk/dedu/lib/localstore"
+	"github.com/steinarvk/dedu/lib/pcloud"
+	"github.com/steinarvk/dedu/lib/s3store"
+	"github.com/steinarvk/dedu/lib/webdavstore"
+)
+
+func (d *Dedu) configuredStorage() []string {
+	var rv []string
+	if d.PcloudCreds != nil {
+		rv = append(rv, "pcloud")
+	}
+	if d.LocalCreds != nil {
+		rv = append(rv, "local")
+	}
+	if d.S3Creds != nil {
+		rv = append(rv, "s3")
+	}
+	if d.WebdavCreds != nil {
+		rv = append(rv, "webdav")
+	}
+	return rv
+}
+
+// HTTPClient returns a client for the HTTP-based storage backends,
+// configured according to the http_client config section.
+func (d *Dedu) HTTPClient() (*http.Client, error) {
+	return httpclient.New(d.Config.HttpClient)
+}
+
+func (d *Dedu) OpenStorage(ctx context.Context) (blobstore.BlobStore, error) {
+	configured := d.configuredStorage()
+	if len(configured) == 0 {
+		return nil, fmt.Errorf("No storage_creds provided: no known storage")
+	}
+	if len(configured) > 1 {
+		return nil, fmt.Errorf("Multiple kinds of storage_creds provided (%v): expected exactly one", configured)
+	}
+
+	switch configured[0] {
+	case "pcloud":
+		httpClient, err := d.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		storage, err := pcloud.New(ctx, d.PcloudCreds, d.Config.PcloudTargetFolder, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		return storage.Connection(ctx), nil
+
+	case "local":
+		return localstore.New(filepath.Join(d.LocalCreds.RootDirectory, d.Config.LocalTargetDirectory))
+
+	case "s3":
+		httpClient, err := d.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		return s3store.New(ctx, d.S3Creds, d.Config.S3TargetPrefix, httpClient)
+
+	case "webdav":
+		httpClient, err := d.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		return webdavstore.New(ctx, d.WebdavCreds, d.Config.WebdavTargetFolder, httpClient)
+
+	default:
+		return nil, fmt.Errorf("internal error: unhandled storage kind %q", configured[0])
+	}
+}
@@ -0,0 +1,117 @@
+// Package httpclient builds the HTTP clients used by the storage backends
+// from the http_client section of the dedu config.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+const (
+	DefaultDialTimeout           = 30 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultResponseHeaderTimeout = 2 * time.Minute
+	DefaultIdleConnTimeout       = 90 * time.Second
+)
+
+func parseDuration(field, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid http_client.%s %q: %v", field, value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("Invalid http_client.%s %q: negative", field, value)
+	}
+	return d, nil
+}
+
+// New creates an HTTP client according to cfg, which may be nil.
+//
+// Durations are given in time.ParseDuration format (e.g. "30s"). Unset
+// timeouts get a default, except the overall request timeout, which is
+// unlimited by default since it would also bound large transfers. Unless
+// proxy_url is set, proxies are taken from the environment as usual.
+func New(cfg *pb.HttpClientConfig) (*http.Client, error) {
+	if cfg == nil {
+		cfg = &pb.HttpClientConfig{}
+	}
+
+	timeout, err := parseDuration("timeout", cfg.Timeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout, err := parseDuration("dial_timeout", cfg.DialTimeout, DefaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tlsHandshakeTimeout, err := parseDuration("tls_handshake_timeout", cfg.TlsHandshakeTimeout, DefaultTLSHandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	responseHeaderTimeout, err := parseDuration("response_header_timeout", cfg.ResponseHeaderTimeout, DefaultResponseHeaderTimeout)
+	if err != nil {
+		return nil, err
+	}
+	idleConnTimeout, err := parseDuration("idle_conn_timeout", cfg.IdleConnTimeout, DefaultIdleConnTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyUrl != "" {
+		proxyURL, err := url.Parse(cfg.ProxyUrl)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid http_client.proxy_url %q: %v", cfg.ProxyUrl, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CaCertFile != "" {
+		pemData, err := ioutil.ReadFile(cfg.CaCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading http_client.ca_cert_file %q: %v", cfg.CaCertFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("No certificates found in http_client.ca_cert_file %q", cfg.CaCertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		IdleConnTimeout:       idleConnTimeout,
+		MaxIdleConns:          100,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
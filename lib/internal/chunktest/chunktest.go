@@ -0,0 +1,124 @@
+// Package chunktest provides the fixtures shared by the tests of the
+// packages that pack, store and reassemble chunks.
+package chunktest
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/keyset"
+
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/deduhash"
+	"github.com/steinarvk/dedu/lib/localstore"
+	"github.com/steinarvk/dedu/lib/obfuscate"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// magicBlockSize is the size of the magic block starting every packed
+// chunk.
+const magicBlockSize = 16
+
+// NewHasher returns a hasher with a fixed key.
+func NewHasher(t testing.TB) *deduhash.Hasher {
+	t.Helper()
+
+	hasher, err := deduhash.New([]byte("test hashing key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+// NewPacker returns a packer with the default settings, a hasher from
+// NewHasher and a freshly generated AES-GCM key.
+func NewPacker(t testing.TB) *deduchunk.Packer {
+	t.Helper()
+
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypter, err := aead.New(kh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &deduchunk.Packer{
+		Hasher:     NewHasher(t),
+		Obfuscator: obfuscate.New(),
+		Encrypter:  encrypter,
+	}
+}
+
+// NewStore returns an empty store in a temporary directory.
+func NewStore(t testing.TB) *localstore.Storage {
+	t.Helper()
+
+	store, err := localstore.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// Data returns n bytes in which no 100-byte chunk repeats.
+func Data(n int) []byte {
+	buf := bytes.NewBuffer(nil)
+	for i := 0; buf.Len() < n; i++ {
+		fmt.Fprintf(buf, "%d,", i)
+	}
+	return buf.Bytes()[:n]
+}
+
+// RenameChunk rewrites the public header of a packed chunk to claim the
+// given chunk ID, as anyone can, since it is only obfuscated.
+func RenameChunk(t testing.TB, p *deduchunk.Packer, packed []byte, chunkId string) []byte {
+	t.Helper()
+
+	magicBytes, err := p.Obfuscator.UnobfuscateBlock(packed[:magicBlockSize], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	magic := &pb.MagicHeader{}
+	if err := proto.Unmarshal(magicBytes, magic); err != nil {
+		t.Fatal(err)
+	}
+	publicEnd := magicBlockSize + int(magic.PublicHeaderLength)
+	publicBytes, err := p.Obfuscator.Unobfuscate(packed[magicBlockSize:publicEnd], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := &pb.PublicHeader{}
+	if err := proto.Unmarshal(publicBytes, public); err != nil {
+		t.Fatal(err)
+	}
+	// Everything after the public header is left as it was.
+	rest := packed[publicEnd:]
+
+	public.ChunkId = chunkId
+	publicBytes, err = proto.Marshal(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicObfuscated, err := p.Obfuscator.Obfuscate(publicBytes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	magic.PublicHeaderLength = int32(len(publicObfuscated))
+	magicBytes, err = proto.Marshal(magic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	magicObfuscated, err := p.Obfuscator.ObfuscateBlock(magicBytes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(append(magicObfuscated, publicObfuscated...), rest...)
+}
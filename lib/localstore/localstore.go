@@ -0,0 +1,243 @@
+// Package localstore stores blobs in a directory on a local filesystem,
+// such as a NAS mount or an external disk.
+//
+// Blobs are spread over two levels of shard directories derived from a
+// hash of the blob name, so that no single directory grows too large:
+//
+//	<root>/3f/a2/<name>
+//
+// Writes go to a temporary file in <root>/tmp which is fsynced and then
+// renamed into place, so a crash never leaves a partial blob under its
+// final name.
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/blobstore"
+)
+
+const (
+	tmpDirName = "tmp"
+	shardDepth = 2
+	dirMode    = os.FileMode(0700)
+	fileMode   = os.FileMode(0600)
+)
+
+var _ blobstore.StreamingBlobStore = &Storage{}
+
+type Storage struct {
+	root string
+}
+
+func validateName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("Invalid blob name %q", name)
+	}
+	return nil
+}
+
+func shards(name string) []string {
+	digest := sha256.Sum256([]byte(name))
+	hexdigest := hex.EncodeToString(digest[:])
+	var rv []string
+	for i := 0; i < shardDepth; i++ {
+		rv = append(rv, hexdigest[2*i:2*i+2])
+	}
+	return rv
+}
+
+func (s *Storage) blobPath(name string) string {
+	components := append([]string{s.root}, shards(name)...)
+	return filepath.Join(append(components, name)...)
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *Storage) Put(ctx context.Context, name string, data []byte) error {
+	return s.PutReader(ctx, name, bytes.NewReader(data), int64(len(data)))
+}
+
+func (s *Storage) PutReader(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	path := s.blobPath(name)
+
+	if _, err := os.Lstat(path); err == nil {
+		return blobstore.AlreadyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	t0 := time.Now()
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("Failed to create shard directory %q: %v", dir, err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Join(s.root, tmpDirName), name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file: %v", err)
+	}
+	tmpName := f.Name()
+
+	if err := func() error {
+		defer f.Close()
+
+		if err := f.Chmod(fileMode); err != nil {
+			return err
+		}
+		n, err := io.Copy(f, io.LimitReader(r, size+1))
+		if err != nil {
+			return err
+		}
+		if n != size {
+			return fmt.Errorf("expected %d bytes, got %d", size, n)
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		return f.Close()
+	}(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("Failed to write %q: %v", tmpName, err)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("Failed to rename %q to %q: %v", tmpName, path, err)
+	}
+
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("Failed to sync directory %q: %v", dir, err)
+	}
+
+	dur := time.Since(t0)
+	logrus.WithFields(logrus.Fields{
+		"size":     size,
+		"duration": dur,
+		"path":     path,
+	}).Infof("Stored file %q", name)
+
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(s.blobPath(name))
+}
+
+func (s *Storage) GetReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	return os.Open(s.blobPath(name))
+}
+
+func (s *Storage) Stat(ctx context.Context, name string) (*blobstore.BlobInfo, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(s.blobPath(name))
+	if err != nil {
+		return nil, err
+	}
+	return &blobstore.BlobInfo{
+		Name:     name,
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+	}, nil
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]blobstore.BlobInfo, error) {
+	var rv []blobstore.BlobInfo
+
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			if depth < shardDepth {
+				if info.IsDir() && len(info.Name()) == 2 {
+					if err := walk(filepath.Join(dir, info.Name()), depth+1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if !info.Mode().IsRegular() || !strings.HasPrefix(info.Name(), prefix) {
+				continue
+			}
+			rv = append(rv, blobstore.BlobInfo{
+				Name:     info.Name(),
+				Size:     info.Size(),
+				Modified: info.ModTime(),
+			})
+		}
+		return nil
+	}
+
+	if err := walk(s.root, 0); err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	return os.Remove(s.blobPath(name))
+}
+
+func New(root string) (*Storage, error) {
+	if root == "" {
+		return nil, fmt.Errorf("No root directory provided")
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to use storage directory %q: %v", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("Unable to use storage directory %q: not a directory", root)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, tmpDirName), dirMode); err != nil {
+		return nil, fmt.Errorf("Failed to create temporary directory in %q: %v", root, err)
+	}
+
+	return &Storage{root: root}, nil
+}
@@ -0,0 +1,208 @@
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/steinarvk/dedu/lib/blobstore"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func listNames(t *testing.T, s *Storage, prefix string) []string {
+	t.Helper()
+	infos, err := s.List(context.Background(), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPutExisting(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	if err := s.Put(ctx, "blob", []byte("original")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "blob", []byte("replacement")); err != blobstore.AlreadyExists {
+		t.Errorf("Put() of existing blob = %v, want AlreadyExists", err)
+	}
+
+	data, err := s.Get(ctx, "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "original" {
+		t.Errorf("Get() = %q after failed overwrite", data)
+	}
+}
+
+func TestRejectsInvalidNames(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.Mkdir(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../escaped", "a/b", "a\\b", "/etc/passwd", ".hidden", "nul\x00l"} {
+		if err := s.Put(ctx, name, []byte("data")); err == nil {
+			t.Errorf("Put(%q) succeeded", name)
+		}
+		if _, err := s.Get(ctx, name); err == nil {
+			t.Errorf("Get(%q) succeeded", name)
+		}
+		if r, err := s.GetReader(ctx, name); err == nil {
+			r.Close()
+			t.Errorf("GetReader(%q) succeeded", name)
+		}
+		if _, err := s.Stat(ctx, name); err == nil {
+			t.Errorf("Stat(%q) succeeded", name)
+		}
+		if err := s.Delete(ctx, name); err == nil {
+			t.Errorf("Delete(%q) succeeded", name)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Errorf("%d entries next to the storage directory, want 1", len(infos))
+	}
+}
+
+func TestListPrefixAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	var want []string
+	shardDirs := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("abc-%02d", i)
+		want = append(want, name)
+		shardDirs[filepath.Dir(s.blobPath(name))] = true
+		if err := s.Put(ctx, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := s.Put(ctx, fmt.Sprintf("xyz-%02d", i), []byte("other")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(shardDirs) < 2 {
+		t.Fatalf("test blobs are all in shard %v", shardDirs)
+	}
+
+	got := listNames(t, s, "abc-")
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("List(\"abc-\") = %v, want %v", got, want)
+	}
+	if got := listNames(t, s, ""); len(got) != 25 {
+		t.Errorf("List(\"\") returned %d blobs, want 25", len(got))
+	}
+	if got := listNames(t, s, "nothing"); len(got) != 0 {
+		t.Errorf("List(\"nothing\") = %v", got)
+	}
+
+	infos, err := s.List(ctx, "abc-07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Size != int64(len("abc-07")) {
+		t.Errorf("List(\"abc-07\") = %v", infos)
+	}
+}
+
+func TestMissingBlob(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	if _, err := s.Get(ctx, "missing"); !os.IsNotExist(err) {
+		t.Errorf("Get() = %v, want not-exist error", err)
+	}
+	if _, err := s.GetReader(ctx, "missing"); !os.IsNotExist(err) {
+		t.Errorf("GetReader() = %v, want not-exist error", err)
+	}
+	if _, err := s.Stat(ctx, "missing"); !os.IsNotExist(err) {
+		t.Errorf("Stat() = %v, want not-exist error", err)
+	}
+	if err := s.Delete(ctx, "missing"); !os.IsNotExist(err) {
+		t.Errorf("Delete() = %v, want not-exist error", err)
+	}
+}
+
+// failingReader returns data and then err.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (f *failingReader) Read(buf []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	n := copy(buf, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+func TestFailedWriteLeavesNothing(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	for name, r := range map[string]io.Reader{
+		"short":  bytes.NewReader([]byte("too short")),
+		"failed": &failingReader{data: []byte("some data"), err: errors.New("read failed")},
+	} {
+		if err := s.PutReader(ctx, name, r, 100); err == nil {
+			t.Errorf("PutReader(%q) succeeded", name)
+		}
+		if _, err := s.Stat(ctx, name); !os.IsNotExist(err) {
+			t.Errorf("Stat(%q) after failed write = %v, want not-exist error", name, err)
+		}
+	}
+
+	if got := listNames(t, s, ""); len(got) != 0 {
+		t.Errorf("List() after failed writes = %v", got)
+	}
+	tmpFiles, err := ioutil.ReadDir(filepath.Join(s.root, tmpDirName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmpFiles) != 0 {
+		t.Errorf("%d temporary files left after failed writes", len(tmpFiles))
+	}
+
+	// The name can still be used afterwards.
+	if err := s.Put(ctx, "short", []byte("fine")); err != nil {
+		t.Errorf("Put() after failed write = %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package pcloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// authState holds the auth token used by a Storage and all connections
+// derived from it.
+//
+// If the credentials contain a stored auth_token it is used as-is and
+// never refreshed. Otherwise the username and password are exchanged for
+// a token once, using a POST body so that they never appear in a URL, and
+// again whenever pcloud reports that the token is no longer valid.
+type authState struct {
+	creds *pb.PcloudCredentials
+
+	mu    sync.Mutex
+	token string
+}
+
+type userinfoAuthResponse struct {
+	Auth string `json:"auth"`
+}
+
+func newAuthState(creds *pb.PcloudCredentials) *authState {
+	return &authState{creds: creds, token: creds.AuthToken}
+}
+
+func (a *authState) canLogin() bool {
+	return a.creds.Username != "" && a.creds.Password != ""
+}
+
+func (a *authState) get(ctx context.Context, s *Storage) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" {
+		return a.token, nil
+	}
+
+	if !a.canLogin() {
+		return "", fmt.Errorf("No pcloud auth token or password available: %w", ErrAuth)
+	}
+
+	token, err := s.login(ctx)
+	if err != nil {
+		return "", err
+	}
+	a.token = token
+	return token, nil
+}
+
+// invalidate forgets the given token, if it is still the current one.
+func (a *authState) invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == token {
+		a.token = ""
+	}
+}
+
+func (s *Storage) login(ctx context.Context) (string, error) {
+	endpoint := "userinfo"
+
+	form := url.Values{}
+	form.Set("getauth", "1")
+	form.Set("logout", "1")
+	form.Set("username", s.auth.creds.Username)
+	form.Set("password", s.auth.creds.Password)
+
+	client := s.client
+	if client == nil {
+		client = s.createClient(ctx)
+	}
+
+	fields := logrus.Fields{
+		"endpoint": endpoint,
+		"username": s.auth.creds.Username,
+	}
+
+	var token string
+	err := s.withRetries(ctx, fields, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", s.formatURL(endpoint, nil, ""), strings.NewReader(form.Encode()))
+		if err != nil {
+			return permanentError{err}
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		t0 := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("pcloud login failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		authResp := userinfoAuthResponse{}
+		// The response contains the token, so it must not be logged.
+		if err := decodeResponse(endpoint, resp, &authResp, false); err != nil {
+			return err
+		}
+		if authResp.Auth == "" {
+			return permanentError{fmt.Errorf("pcloud login returned no auth token")}
+		}
+
+		logrus.WithFields(fields).WithFields(logrus.Fields{
+			"duration": time.Since(t0),
+		}).Infof("Logged in to pcloud")
+
+		token = authResp.Auth
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// AuthToken returns the auth token in use, logging in if necessary. It
+// can be stored as storage_creds.pcloud.auth_token in place of the
+// password.
+func (s *Storage) AuthToken(ctx context.Context) (string, error) {
+	return s.auth.get(ctx, s)
+}
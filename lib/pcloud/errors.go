@@ -0,0 +1,116 @@
+package pcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+var (
+	ErrAuth          = errors.New("pcloud authentication failed")
+	ErrQuotaExceeded = errors.New("pcloud quota exceeded")
+	ErrRateLimited   = errors.New("pcloud rate limit exceeded")
+)
+
+// Result codes documented at https://docs.pcloud.com/errors/.
+const (
+	resultLoginRequired       = 1000
+	resultLoginFailed         = 2000
+	resultDirectoryNotExist   = 2005
+	resultOverQuota           = 2008
+	resultFileNotFound        = 2009
+	resultInvalidAccessToken  = 2094
+	resultTooManyLogins       = 4000
+	resultInternalError       = 5000
+	resultInternalUploadError = 5001
+)
+
+// Error is a failure reported by the pcloud API in the "result" field of
+// a response. It can be matched against ErrAuth, ErrQuotaExceeded and
+// ErrRateLimited with errors.Is.
+type Error struct {
+	Endpoint string
+	Result   int
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pcloud error %d: %s", e.Result, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return e.Result == resultLoginRequired || e.Result == resultLoginFailed || e.Result == resultInvalidAccessToken
+	case ErrQuotaExceeded:
+		return e.Result == resultOverQuota
+	case ErrRateLimited:
+		return e.Result == resultTooManyLogins
+	}
+	return false
+}
+
+func (e *Error) Retryable() bool {
+	switch e.Result {
+	case resultTooManyLogins, resultInternalError, resultInternalUploadError:
+		return true
+	}
+	return false
+}
+
+func makePcloudError(pce *Error) error {
+	if pce.Result == resultFileNotFound || pce.Result == resultDirectoryNotExist {
+		return os.ErrNotExist
+	}
+	return pce
+}
+
+type httpError struct {
+	status int
+	data   []byte
+}
+
+func (e httpError) Error() string {
+	return fmt.Sprintf("HTTP error %d", e.status)
+}
+
+func (e httpError) Retryable() bool {
+	return e.status >= 500 || e.status == http.StatusTooManyRequests || e.status == http.StatusRequestTimeout
+}
+
+// IsRetryable returns whether err is likely to be transient, such that
+// the failed operation is worth trying again.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// Other network errors, such as TLS certificate failures, are not
+	// transient. (Every error from http.Client.Do is a net.Error.)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
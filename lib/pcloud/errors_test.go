@@ -0,0 +1,53 @@
+package pcloud
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	urlError := func(err error) error {
+		return fmt.Errorf("pcloud call %q failed: %w", "stat", &url.Error{Op: "Get", URL: "https://api.pcloud.com/stat", Err: err})
+	}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"timeout", urlError(timeoutError{}), true},
+		{"connection reset", urlError(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"connection refused", urlError(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"unexpected EOF", urlError(io.ErrUnexpectedEOF), true},
+		{"certificate", urlError(x509.UnknownAuthorityError{}), false},
+		{"DNS", urlError(&net.DNSError{Err: "no such host", Name: "api.pcloud.invalid"}), false},
+		{"canceled", urlError(context.Canceled), false},
+		{"HTTP 503", httpError{status: 503}, true},
+		{"HTTP 429", httpError{status: 429}, true},
+		{"HTTP 404", httpError{status: 404}, false},
+		{"internal error", &Error{Result: resultInternalError}, true},
+		{"login failed", &Error{Result: resultLoginFailed}, false},
+		{"over quota", &Error{Result: resultOverQuota}, false},
+		{"permanent", permanentError{httpError{status: 503}}, false},
+		{"other", errors.New("something else"), false},
+	} {
+		if got := IsRetryable(tc.err); got != tc.want {
+			t.Errorf("IsRetryable(%s: %v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
@@ -6,46 +6,76 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/blobstore"
 
 	pb "github.com/steinarvk/dedu/gen/dedupb"
 )
 
 var (
-	AlreadyExists = errors.New("File already exists")
+	AlreadyExists = blobstore.AlreadyExists
 )
 
 const (
 	apiBaseURL = "https://api.pcloud.com/"
+
+	maxErrorBodySize = 64 * 1024
+)
+
+const (
+	pcloudTimeFormat = time.RFC1123Z
 )
 
+var _ blobstore.StreamingBlobStore = &Storage{}
+
 type Storage struct {
-	creds  *pb.PcloudCredentials
-	folder string
-	client *http.Client
+	creds      *pb.PcloudCredentials
+	folder     string
+	baseURL    *url.URL
+	httpClient *http.Client
+	client     *http.Client
+	retry      RetryPolicy
+	auth       *authState
 }
 
 func (s *Storage) Connection(ctx context.Context) *Storage {
 	client := s.createClient(ctx)
 	return &Storage{
-		creds:  s.creds,
-		folder: s.folder,
-		client: client,
+		creds:      s.creds,
+		folder:     s.folder,
+		baseURL:    s.baseURL,
+		httpClient: s.httpClient,
+		client:     client,
+		retry:      s.retry,
+		auth:       s.auth,
 	}
 }
 
 func (s *Storage) Put(ctx context.Context, name string, data []byte) error {
+	return s.PutReader(ctx, name, bytes.NewReader(data), int64(len(data)))
+}
+
+// PutReader uploads size bytes read from r, streaming them directly to
+// the HTTP connection.
+func (s *Storage) PutReader(ctx context.Context, name string, r io.Reader, size int64) error {
 	_, err := s.ChecksumFileSha1(ctx, filepath.Join(s.folder, name))
-	if !os.IsNotExist(err) {
+	if err == nil {
 		return AlreadyExists
 	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Unable to check whether %q exists: %w", name, err)
+	}
 
 	args := map[string]string{
 		"path":      s.folder,
@@ -53,14 +83,14 @@ func (s *Storage) Put(ctx context.Context, name string, data []byte) error {
 		"nopartial": "1",
 	}
 	t0 := time.Now()
-	err = s.getOrPost(ctx, "uploadfile", args, nil, true, data)
+	err = s.getOrPost(ctx, "uploadfile", args, nil, true, r, size)
 	dur := time.Since(t0)
 	if err != nil {
 		return err
 	}
-	speed := float64(len(data)) / dur.Seconds()
+	speed := float64(size) / dur.Seconds()
 	logrus.WithFields(logrus.Fields{
-		"size":     len(data),
+		"size":     size,
 		"duration": dur,
 		"speed":    speed,
 	}).Infof("Uploaded file %q", name)
@@ -72,7 +102,86 @@ type getFilelinkResponse struct {
 	Hosts []string `json:"hosts"`
 }
 
+// Get downloads a whole file, retrying if the download is interrupted.
 func (s *Storage) Get(ctx context.Context, name string) ([]byte, error) {
+	var data []byte
+	fields := logrus.Fields{"filename": name}
+	err := s.withRetries(ctx, fields, func() error {
+		// GetReader does its own retrying of the initial request.
+		r, err := s.GetReader(ctx, name)
+		if err != nil {
+			return permanentError{err}
+		}
+		defer r.Close()
+
+		data, err = ioutil.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// downloadReader wraps the body of a file download and logs the outcome
+// of the download when closed.
+type downloadReader struct {
+	body     io.ReadCloser
+	host     string
+	filename string
+	t0       time.Time
+	size     int64
+	finalErr error
+	eof      bool
+}
+
+func (d *downloadReader) Read(buf []byte) (int, error) {
+	n, err := d.body.Read(buf)
+	d.size += int64(n)
+	if err == io.EOF {
+		d.eof = true
+	} else if err != nil {
+		d.finalErr = err
+		err = fmt.Errorf("Download error (from %q): %w", d.host, err)
+	}
+	return n, err
+}
+
+func (d *downloadReader) Close() error {
+	dur := time.Since(d.t0)
+	switch {
+	case d.finalErr != nil:
+		logrus.WithFields(logrus.Fields{
+			"host":     d.host,
+			"filename": d.filename,
+			"duration": dur,
+			"status":   "failure",
+		}).Errorf("Download from pcloud failed: %v", d.finalErr)
+	case d.eof:
+		speed := float64(d.size) / dur.Seconds()
+		logrus.WithFields(logrus.Fields{
+			"host":     d.host,
+			"filename": d.filename,
+			"size":     d.size,
+			"speed":    speed,
+			"duration": dur,
+			"status":   "ok",
+		}).Infof("Download from pcloud successful")
+	default:
+		logrus.WithFields(logrus.Fields{
+			"host":     d.host,
+			"filename": d.filename,
+			"size":     d.size,
+			"duration": dur,
+			"status":   "closed",
+		}).Infof("Download from pcloud closed before EOF")
+	}
+	return d.body.Close()
+}
+
+// GetReader starts downloading a file and returns a reader streaming its
+// contents from the HTTP connection. The caller must close it.
+func (s *Storage) GetReader(ctx context.Context, name string) (io.ReadCloser, error) {
 	args := map[string]string{"path": filepath.Join(s.folder, name)}
 	resp := getFilelinkResponse{}
 	if err := s.call(ctx, "getfilelink", args, &resp); err != nil {
@@ -84,12 +193,15 @@ func (s *Storage) Get(ctx context.Context, name string) ([]byte, error) {
 	host := resp.Hosts[0]
 
 	u := url.URL{
-		Scheme: "https",
+		Scheme: s.baseURL.Scheme,
 		Host:   host,
 		Path:   resp.Path,
 	}
 
-	httpClient := s.createClient(ctx)
+	httpClient := s.client
+	if httpClient == nil {
+		httpClient = s.createClient(ctx)
+	}
 
 	t0 := time.Now()
 	logrus.WithFields(logrus.Fields{
@@ -97,51 +209,142 @@ func (s *Storage) Get(ctx context.Context, name string) ([]byte, error) {
 		"filename": name,
 	}).Infof("Downloading from pcloud")
 
-	var finalErr error
-	var bodyData []byte
-	defer func() {
-		dur := time.Since(t0)
-		if finalErr != nil {
-			logrus.WithFields(logrus.Fields{
-				"host":     host,
-				"filename": name,
-				"duration": dur,
-				"status":   "failure",
-			}).Errorf("Download from pcloud failed: %v", finalErr)
-		} else {
-			speed := float64(len(bodyData)) / dur.Seconds()
-			logrus.WithFields(logrus.Fields{
-				"host":     host,
-				"filename": name,
-				"size":     len(bodyData),
-				"speed":    speed,
-				"duration": dur,
-				"status":   "ok",
-			}).Infof("Download from pcloud successful")
+	fields := logrus.Fields{
+		"host":     host,
+		"filename": name,
+	}
+
+	var httpResp *http.Response
+	if err := s.withRetries(ctx, fields, func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+		if err != nil {
+			return permanentError{err}
 		}
-	}()
 
-	httpResp, err := httpClient.Get(u.String())
-	if err != nil {
-		finalErr = err
-		return nil, fmt.Errorf("Download error (from %q): %v", host, err)
-	}
-	defer httpResp.Body.Close()
-	bodyData, err = ioutil.ReadAll(httpResp.Body)
-	if err != nil {
-		finalErr = err
-		return nil, fmt.Errorf("Download error (from %q): %v", host, err)
+		httpResp, err = httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("Download error (from %q): %w", host, err)
+		}
+
+		if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+			defer httpResp.Body.Close()
+			data, _ := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxErrorBodySize))
+			return fmt.Errorf("Download error (from %q): %w", host, httpError{status: httpResp.StatusCode, data: data})
+		}
+
+		return nil
+	}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"host":     host,
+			"filename": name,
+			"duration": time.Since(t0),
+			"status":   "failure",
+		}).Errorf("Download from pcloud failed: %v", err)
+		return nil, err
 	}
 
-	return bodyData, nil
+	return &downloadReader{
+		body:     httpResp.Body,
+		host:     host,
+		filename: name,
+		t0:       t0,
+	}, nil
+}
+
+type listfolderResponse struct {
+	Metadata struct {
+		Contents []metadataResponse `json:"contents"`
+	} `json:"metadata"`
 }
 
-func (s *Storage) List(ctx context.Context, prefix string) ([]string, error) {
-	return nil, fmt.Errorf("Not implemented")
+// List returns the files in the target folder whose names begin with
+// prefix, sorted by name. pcloud returns the whole folder listing in a
+// single response, so filtering happens client-side.
+func (s *Storage) List(ctx context.Context, prefix string) ([]blobstore.BlobInfo, error) {
+	folder := s.folder
+	if folder == "" {
+		folder = "/"
+	}
+
+	resp := listfolderResponse{}
+	args := map[string]string{"path": folder, "noshares": "1"}
+	if err := s.call(ctx, "listfolder", args, &resp); err != nil {
+		return nil, err
+	}
+
+	var rv []blobstore.BlobInfo
+	for _, entry := range resp.Metadata.Contents {
+		if entry.IsFolder || !strings.HasPrefix(entry.Name, prefix) {
+			continue
+		}
+		info, err := entry.blobInfo()
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, *info)
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].Name < rv[j].Name
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"folder":  folder,
+		"prefix":  prefix,
+		"entries": len(resp.Metadata.Contents),
+		"matches": len(rv),
+	}).Infof("Listed pcloud folder")
+
+	return rv, nil
 }
 
 type metadataResponse struct {
-	Size int64 `json:"size"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified"`
+	IsFolder bool   `json:"isfolder"`
+}
+
+func (m metadataResponse) blobInfo() (*blobstore.BlobInfo, error) {
+	rv := &blobstore.BlobInfo{
+		Name: m.Name,
+		Size: m.Size,
+	}
+	if m.Modified != "" {
+		t, err := time.Parse(pcloudTimeFormat, m.Modified)
+		if err != nil {
+			return nil, fmt.Errorf("Bad modification time %q for %q: %v", m.Modified, m.Name, err)
+		}
+		rv.Modified = t
+	}
+	return rv, nil
+}
+
+type statResponse struct {
+	Metadata metadataResponse `json:"metadata"`
+}
+
+func (s *Storage) Stat(ctx context.Context, name string) (*blobstore.BlobInfo, error) {
+	resp := statResponse{}
+	args := map[string]string{"path": filepath.Join(s.folder, name)}
+	if err := s.call(ctx, "stat", args, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Metadata.IsFolder {
+		return nil, fmt.Errorf("%q is a folder", name)
+	}
+	return resp.Metadata.blobInfo()
+}
+
+func (s *Storage) Delete(ctx context.Context, name string) error {
+	args := map[string]string{"path": filepath.Join(s.folder, name)}
+	if err := s.call(ctx, "deletefile", args, nil); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"filename": name,
+	}).Infof("Deleted file %q", name)
+	return nil
 }
 
 type checksumResponse struct {
@@ -159,21 +362,19 @@ func (s *Storage) ChecksumFileSha1(ctx context.Context, path string) (string, er
 	return resp.Sha1, nil
 }
 
-func (s *Storage) formatURL(endpoint string, args map[string]string) string {
+func (s *Storage) formatURL(endpoint string, args map[string]string, authToken string) string {
 	v := url.Values{}
-	v.Set("username", s.creds.Username)
-	v.Set("password", s.creds.Password)
+	if authToken != "" {
+		v.Set("auth", authToken)
+	}
 	if args != nil {
 		for key, val := range args {
 			v.Set(key, val)
 		}
 	}
-	u := url.URL{
-		Scheme:   "https",
-		Host:     "api.pcloud.com",
-		Path:     endpoint,
-		RawQuery: v.Encode(),
-	}
+	u := *s.baseURL
+	u.Path = path.Join("/", u.Path, endpoint)
+	u.RawQuery = v.Encode()
 	return u.String()
 }
 
@@ -182,36 +383,60 @@ type pcloudBasicResult struct {
 	Error  string `json:"error"`
 }
 
-type pcloudError struct {
-	result  int
-	message string
+func (s *Storage) call(ctx context.Context, endpoint string, args map[string]string, dest interface{}) error {
+	return s.getOrPost(ctx, endpoint, args, dest, false, nil, 0)
 }
 
-func makePcloudError(pce pcloudError) error {
-	if pce.result == 2009 {
-		return os.ErrNotExist
+// getOrPost calls a pcloud API endpoint, retrying transient failures
+// according to the retry policy. A POST body is only retried if it can
+// be rewound, i.e. if it implements io.Seeker.
+func (s *Storage) getOrPost(ctx context.Context, endpoint string, args map[string]string, dest interface{}, post bool, body io.Reader, size int64) error {
+	seeker, rewindable := body.(io.Seeker)
+	if body == nil {
+		rewindable = true
 	}
-	return pce
-}
-
-func (e pcloudError) Error() string {
-	return fmt.Sprintf("pcloud error %d: %s", e.result, e.message)
-}
 
-type httpError struct {
-	status int
-	data   []byte
-}
-
-func (e httpError) Error() string {
-	return fmt.Sprintf("HTTP error %d", e.status)
-}
+	fields := logrus.Fields{
+		"endpoint": endpoint,
+		"post":     post,
+	}
 
-func (s *Storage) call(ctx context.Context, endpoint string, args map[string]string, dest interface{}) error {
-	return s.getOrPost(ctx, endpoint, args, dest, false, nil)
+	attempt := 0
+	reauthenticated := false
+	return s.withRetries(ctx, fields, func() error {
+		for {
+			attempt++
+			if attempt > 1 {
+				if !rewindable {
+					return permanentError{fmt.Errorf("Unable to retry pcloud call %q: request body cannot be rewound", endpoint)}
+				}
+				if seeker != nil {
+					if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+						return permanentError{fmt.Errorf("Unable to rewind request body: %v", err)}
+					}
+				}
+			}
+
+			// Logging in does its own retrying.
+			authToken, err := s.auth.get(ctx, s)
+			if err != nil {
+				return permanentError{err}
+			}
+
+			err = s.getOrPostOnce(ctx, endpoint, args, authToken, dest, post, body, size)
+			if errors.Is(err, ErrAuth) && !reauthenticated && s.auth.canLogin() {
+				// The token has most likely expired; log in again.
+				reauthenticated = true
+				s.auth.invalidate(authToken)
+				logrus.WithFields(fields).Warningf("pcloud rejected auth token (%v); logging in again", err)
+				continue
+			}
+			return err
+		}
+	})
 }
 
-func (s *Storage) getOrPost(ctx context.Context, endpoint string, args map[string]string, dest interface{}, post bool, postData []byte) error {
+func (s *Storage) getOrPostOnce(ctx context.Context, endpoint string, args map[string]string, authToken string, dest interface{}, post bool, body io.Reader, size int64) error {
 	logrus.WithFields(logrus.Fields{
 		"endpoint": endpoint,
 		"post":     post,
@@ -232,45 +457,57 @@ func (s *Storage) getOrPost(ctx context.Context, endpoint string, args map[strin
 	if client == nil {
 		client = s.createClient(ctx)
 	}
-	u := s.formatURL(endpoint, args)
+	u := s.formatURL(endpoint, args, authToken)
 
-	var resp *http.Response
-	var err error
+	method := "GET"
 	if post {
-		buf := bytes.NewReader(postData)
-		var req *http.Request
-		req, err = http.NewRequest("POST", u, buf)
-		if err != nil {
-			return err
+		method = "POST"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if post {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
 		}
-		req.Header.Set("Content-Length", fmt.Sprintf("%d", len(postData)))
 		req.Header.Set("Content-Type", "application/octet-stream")
-		resp, err = client.Do(req)
-	} else {
-		resp, err = client.Get(u)
 	}
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("pcloud call %q failed: %v", endpoint, err)
+		return fmt.Errorf("pcloud call %q failed: %w", endpoint, err)
 	}
 	status = resp.Status
 	defer resp.Body.Close()
 
+	return decodeResponse(endpoint, resp, dest, true)
+}
+
+func decodeResponse(endpoint string, resp *http.Response, dest interface{}, logBody bool) error {
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("Call to pcloud %q failed: Read error: %v", endpoint, err)
+		return fmt.Errorf("Call to pcloud %q failed: Read error: %w", endpoint, err)
+	}
+
+	if logBody {
+		logrus.Debugf("pcloud response: %s", string(data))
 	}
 
-	logrus.Debugf("pcloud response: %s", string(data))
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 299
 
 	basicResponse := pcloudBasicResult{}
 	if err := json.Unmarshal(data, &basicResponse); err != nil {
+		if !ok {
+			return httpError{status: resp.StatusCode, data: data}
+		}
 		return fmt.Errorf("Call to pcloud %q failed: JSON decode error: %v", endpoint, err)
 	}
 	if basicResponse.Result != 0 {
-		return makePcloudError(pcloudError{result: basicResponse.Result, message: basicResponse.Error})
+		return makePcloudError(&Error{Endpoint: endpoint, Result: basicResponse.Result, Message: basicResponse.Error})
 	}
 
-	ok := resp.StatusCode >= 200 && resp.StatusCode < 299
 	if !ok {
 		return httpError{status: resp.StatusCode, data: data}
 	}
@@ -293,11 +530,37 @@ func (s *Storage) checkFolderExists(ctx context.Context, path string) error {
 }
 
 func (s *Storage) createClient(ctx context.Context) *http.Client {
+	if s.httpClient != nil {
+		return s.httpClient
+	}
 	return &http.Client{}
 }
 
-func New(ctx context.Context, creds *pb.PcloudCredentials, folder string) (*Storage, error) {
-	store := &Storage{creds: creds, folder: folder}
+// New connects to pcloud. The API is reached at creds.api_base_url if set
+// (e.g. "https://eapi.pcloud.com" for EU-region accounts), and otherwise
+// at the default US endpoint. If httpClient is nil, a default client is
+// used.
+func New(ctx context.Context, creds *pb.PcloudCredentials, folder string, httpClient *http.Client) (*Storage, error) {
+	rawBaseURL := apiBaseURL
+	if creds.ApiBaseUrl != "" {
+		rawBaseURL = creds.ApiBaseUrl
+	}
+	baseURL, err := url.Parse(rawBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pcloud API base URL %q: %v", rawBaseURL, err)
+	}
+	if baseURL.Scheme != "https" && baseURL.Scheme != "http" {
+		return nil, fmt.Errorf("Invalid pcloud API base URL %q: scheme must be http or https", rawBaseURL)
+	}
+
+	store := &Storage{
+		creds:      creds,
+		folder:     folder,
+		baseURL:    baseURL,
+		httpClient: httpClient,
+		retry:      DefaultRetryPolicy,
+		auth:       newAuthState(creds),
+	}
 
 	conn := store.Connection(ctx)
 
@@ -0,0 +1,289 @@
+package pcloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/dedu/lib/blobstore"
+	"github.com/steinarvk/dedu/lib/pcloud/pcloudfake"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+	Multiplier:     2,
+}
+
+func newFakeServer(t *testing.T) *pcloudfake.Server {
+	server := pcloudfake.New("user@example.com", "secret")
+	t.Cleanup(server.Close)
+	server.CreateFolder("/blobs")
+	return server
+}
+
+func newTestStorage(t *testing.T, server *pcloudfake.Server) *Storage {
+	t.Helper()
+
+	s, err := New(context.Background(), server.Credentials(), "/blobs", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.retry = testRetryPolicy
+	return s
+}
+
+// logins returns the number of login requests the server has received.
+// Login is the only userinfo call made after New.
+func logins(server *pcloudfake.Server) int {
+	return server.Calls("userinfo")
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	data := []byte("hello, world")
+	if err := s.Put(ctx, "1-abc", data); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if got, ok := server.File("/blobs/1-abc"); !ok || !bytes.Equal(got, data) {
+		t.Errorf("stored file = %q, %v; want %q", got, ok, data)
+	}
+
+	got, err := s.Get(ctx, "1-abc")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Get() = %q, %v; want %q", got, err, data)
+	}
+
+	r, err := s.GetReader(ctx, "1-abc")
+	if err != nil {
+		t.Fatalf("GetReader() = %v", err)
+	}
+	got, err = ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("GetReader() read %q, %v; want %q", got, err, data)
+	}
+
+	info, err := s.Stat(ctx, "1-abc")
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	if info.Name != "1-abc" || info.Size != int64(len(data)) || info.Modified.IsZero() {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get(missing) = %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat(missing) = %v, want os.ErrNotExist", err)
+	}
+
+	if err := s.Delete(ctx, "1-abc"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if _, ok := server.File("/blobs/1-abc"); ok {
+		t.Errorf("file still stored after Delete()")
+	}
+	if err := s.Delete(ctx, "1-abc"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Delete(deleted) = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestPutAlreadyExists(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	server.PutFile("/blobs/1-abc", []byte("original"))
+	if err := s.Put(ctx, "1-abc", []byte("replacement")); err != blobstore.AlreadyExists {
+		t.Errorf("Put() = %v, want AlreadyExists", err)
+	}
+	if got, _ := server.File("/blobs/1-abc"); string(got) != "original" {
+		t.Errorf("existing file overwritten with %q", got)
+	}
+	if n := server.Calls("uploadfile"); n != 0 {
+		t.Errorf("Put() made %d uploads, want 0", n)
+	}
+}
+
+func TestPutFailsIfExistenceCheckFails(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	for _, fault := range []pcloudfake.Fault{
+		{Endpoint: "checksumfile", HTTPStatus: 503},
+		{Endpoint: "checksumfile", Result: pcloudfake.ResultOverQuota, Message: "User is over quota."},
+	} {
+		server.ClearFaults()
+		server.InjectFault(fault)
+
+		err := s.Put(ctx, "1-abc", []byte("data"))
+		if err == nil || err == blobstore.AlreadyExists {
+			t.Errorf("Put() with checksumfile fault %+v = %v, want an error", fault, err)
+		}
+		if _, ok := server.File("/blobs/1-abc"); ok {
+			t.Errorf("Put() with checksumfile fault %+v uploaded the file", fault)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	server.InjectFault(pcloudfake.Fault{Endpoint: "uploadfile", HTTPStatus: 503, Times: 2})
+	if err := s.Put(ctx, "1-abc", []byte("hello, world")); err != nil {
+		t.Fatalf("Put() with 2 transient failures = %v", err)
+	}
+	if n := server.Calls("uploadfile"); n != 3 {
+		t.Errorf("Put() made %d upload attempts, want 3", n)
+	}
+
+	server.InjectFault(pcloudfake.Fault{Endpoint: "stat", Result: pcloudfake.ResultInternalError, Times: 1})
+	if _, err := s.Stat(ctx, "1-abc"); err != nil {
+		t.Errorf("Stat() with transient failure = %v", err)
+	}
+
+	server.InjectFault(pcloudfake.Fault{Endpoint: pcloudfake.Download, TruncateAfter: 5, Times: 1})
+	if got, err := s.Get(ctx, "1-abc"); err != nil || string(got) != "hello, world" {
+		t.Errorf("Get() with truncated download = %q, %v", got, err)
+	}
+
+	server.InjectFault(pcloudfake.Fault{Endpoint: "stat", HTTPStatus: 503})
+	before := server.Calls("stat")
+	if _, err := s.Stat(ctx, "1-abc"); err == nil {
+		t.Errorf("Stat() with permanent 503 succeeded")
+	}
+	if n := server.Calls("stat") - before; n != testRetryPolicy.MaxAttempts {
+		t.Errorf("Stat() made %d attempts, want %d", n, testRetryPolicy.MaxAttempts)
+	}
+	server.ClearFaults()
+
+	server.InjectFault(pcloudfake.Fault{Endpoint: "stat", Result: pcloudfake.ResultOverQuota})
+	before = server.Calls("stat")
+	if _, err := s.Stat(ctx, "1-abc"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Stat() = %v, want ErrQuotaExceeded", err)
+	}
+	if n := server.Calls("stat") - before; n != 1 {
+		t.Errorf("Stat() retried a permanent error: %d attempts", n)
+	}
+}
+
+func TestPutReaderDoesNotRetryUnrewindableBody(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	server.InjectFault(pcloudfake.Fault{Endpoint: "uploadfile", HTTPStatus: 503, Times: 1})
+	body := ioutil.NopCloser(strings.NewReader("data"))
+	if err := s.PutReader(ctx, "1-abc", body, 4); err == nil {
+		t.Errorf("PutReader() with transient failure succeeded")
+	}
+	if n := server.Calls("uploadfile"); n != 1 {
+		t.Errorf("PutReader() made %d upload attempts, want 1", n)
+	}
+}
+
+func TestLoginAgainAfterTokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	before := logins(server)
+	server.ExpireTokens()
+
+	if err := s.Put(ctx, "1-abc", []byte("data")); err != nil {
+		t.Fatalf("Put() after token expiry = %v", err)
+	}
+	if n := logins(server) - before; n != 1 {
+		t.Errorf("logged in %d times after token expiry, want 1", n)
+	}
+
+	// Connections share the login.
+	conn := s.Connection(ctx)
+	server.ExpireTokens()
+	if _, err := conn.Stat(ctx, "1-abc"); err != nil {
+		t.Fatalf("Stat() after token expiry = %v", err)
+	}
+	if _, err := s.Stat(ctx, "1-abc"); err != nil {
+		t.Fatalf("Stat() after token expiry = %v", err)
+	}
+	if n := logins(server) - before; n != 2 {
+		t.Errorf("logged in %d times after two expiries, want 2", n)
+	}
+}
+
+func TestLoginFailureIsNotRetried(t *testing.T) {
+	server := newFakeServer(t)
+
+	creds := server.Credentials()
+	creds.Password = "wrong"
+	if _, err := New(context.Background(), creds, "/blobs", server.Client()); err == nil || !strings.Contains(err.Error(), "pcloud error 2000") {
+		t.Errorf("New() with wrong password = %v, want login failure", err)
+	}
+	if n := logins(server); n != 1 {
+		t.Errorf("made %d login attempts, want 1", n)
+	}
+}
+
+func TestStoredTokenIsNotRefreshed(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+
+	creds := server.Credentials()
+	creds.Password = ""
+	creds.AuthToken = server.IssueToken()
+	s, err := New(ctx, creds, "/blobs", server.Client())
+	if err != nil {
+		t.Fatalf("New() with stored token = %v", err)
+	}
+	s.retry = testRetryPolicy
+
+	server.ExpireTokens()
+	before := logins(server)
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, ErrAuth) {
+		t.Errorf("Stat() with expired stored token = %v, want ErrAuth", err)
+	}
+	if n := logins(server) - before; n != 0 {
+		t.Errorf("made %d login attempts without a password", n)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	s := newTestStorage(t, server)
+
+	for _, name := range []string{"1-c", "1-a", "1-b", "2-a"} {
+		server.PutFile("/blobs/"+name, []byte(name))
+	}
+	server.CreateFolder("/blobs/1-folder")
+	server.PutFile("/other/1-d", []byte("outside the folder"))
+
+	infos, err := s.List(ctx, "1-")
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	var got []string
+	for _, info := range infos {
+		got = append(got, info.Name)
+		if info.Size != 3 || info.Modified.IsZero() {
+			t.Errorf("List() entry %+v has wrong size or time", info)
+		}
+	}
+	if want := "1-a,1-b,1-c"; strings.Join(got, ",") != want {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
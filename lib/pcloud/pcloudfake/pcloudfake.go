@@ -0,0 +1,698 @@
+// Package pcloudfake is an in-process emulation of the subset of the
+// pcloud API used by lib/pcloud, for exercising the pcloud client and the
+// commands built on it without an account or network access.
+//
+// The emulation covers userinfo (including the getauth login flow),
+// listfolder, uploadfile, checksumfile, stat, deletefile and getfilelink,
+// plus the download of file links. Faults (error results, HTTP errors,
+// latency and truncated bodies) can be injected per endpoint.
+package pcloudfake
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// Download is the endpoint name used to match faults against downloads
+// of the links returned by getfilelink.
+const Download = "download"
+
+const (
+	downloadPathPrefix = "/dl/"
+	timeFormat         = time.RFC1123Z
+)
+
+// Result codes returned by the fake, as documented at
+// https://docs.pcloud.com/errors/.
+const (
+	ResultLoginRequired      = 1000
+	ResultNoFullPath         = 1004
+	ResultLoginFailed        = 2000
+	ResultInvalidName        = 2001
+	ResultDirectoryNotExist  = 2005
+	ResultOverQuota          = 2008
+	ResultFileNotFound       = 2009
+	ResultInvalidAccessToken = 2094
+	ResultTooManyLogins      = 4000
+	ResultInternalError      = 5000
+)
+
+// Fault describes a failure to inject into requests to the fake.
+type Fault struct {
+	// Endpoint is the API endpoint (e.g. "uploadfile") or Download to
+	// affect. If empty, every request is affected.
+	Endpoint string
+
+	// Times is the number of matching requests to affect. If zero, the
+	// fault is permanent.
+	Times int
+
+	// Latency delays the response.
+	Latency time.Duration
+
+	// HTTPStatus, if nonzero, makes the fake respond with this status and
+	// a non-JSON body, as a failing proxy or load balancer would.
+	HTTPStatus int
+
+	// Result, if nonzero, makes the fake respond with this pcloud result
+	// code and Message as the error.
+	Result  int
+	Message string
+
+	// TruncateAfter, if positive, makes the fake send only this many bytes
+	// of the response body and then drop the connection. The request is
+	// still carried out.
+	TruncateAfter int
+}
+
+type file struct {
+	data     []byte
+	modified time.Time
+}
+
+// Server is a fake pcloud API server. The API and the file downloads are
+// served from the same address.
+type Server struct {
+	// URL is the API base URL, suitable as storage_creds.pcloud.api_base_url.
+	URL string
+
+	srv      *httptest.Server
+	username string
+	password string
+
+	mu      sync.Mutex
+	folders map[string]time.Time
+	files   map[string]*file
+	tokens  map[string]bool
+	links   map[string]string
+	faults  []*Fault
+	calls   map[string]int
+	quota   int64
+}
+
+// New starts a fake server on a loopback address accepting the given
+// username and password. The root folder "/" exists and is empty.
+func New(username, password string) *Server {
+	s := newServer(username, password)
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// NewWithListener is like New, but serves on the given listener.
+func NewWithListener(l net.Listener, username, password string) *Server {
+	s := newServer(username, password)
+	s.srv = httptest.NewUnstartedServer(s)
+	s.srv.Listener.Close()
+	s.srv.Listener = l
+	s.srv.Start()
+	s.URL = s.srv.URL
+	return s
+}
+
+func newServer(username, password string) *Server {
+	return &Server{
+		username: username,
+		password: password,
+		folders:  map[string]time.Time{"/": time.Now()},
+		files:    map[string]*file{},
+		tokens:   map[string]bool{},
+		links:    map[string]string{},
+		calls:    map[string]int{},
+	}
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Credentials returns credentials for logging in to the fake.
+func (s *Server) Credentials() *pb.PcloudCredentials {
+	return &pb.PcloudCredentials{
+		Username:   s.username,
+		Password:   s.password,
+		ApiBaseUrl: s.URL,
+	}
+}
+
+// Client returns an HTTP client that can talk to the fake.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// CreateFolder creates a folder and any missing parent folders.
+func (s *Server) CreateFolder(folder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folder = path.Clean("/" + folder)
+	for {
+		if _, ok := s.folders[folder]; !ok {
+			s.folders[folder] = time.Now()
+		}
+		if folder == "/" {
+			return
+		}
+		folder = path.Dir(folder)
+	}
+}
+
+// PutFile stores a file directly, without going through the API. The
+// containing folder is created if necessary.
+func (s *Server) PutFile(filename string, data []byte) {
+	filename = path.Clean("/" + filename)
+	s.CreateFolder(path.Dir(filename))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[filename] = &file{data: append([]byte(nil), data...), modified: time.Now()}
+}
+
+// File returns the contents of a stored file.
+func (s *Server) File(filename string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path.Clean("/"+filename)]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), f.data...), true
+}
+
+// Files returns the paths of all stored files, sorted.
+func (s *Server) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rv []string
+	for filename := range s.files {
+		rv = append(rv, filename)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// SetQuota limits the total size of stored files. Zero means unlimited.
+func (s *Server) SetQuota(quota int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quota = quota
+}
+
+// ExpireTokens invalidates all auth tokens handed out so far, forcing
+// clients to log in again.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = map[string]bool{}
+}
+
+// IssueToken returns a valid auth token without going through the login
+// flow, as if it had been stored in the credentials.
+func (s *Server) IssueToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.newTokenLocked()
+}
+
+// InjectFault adds a fault. Faults are matched in the order they were
+// added, and at most one fault applies to each request.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Calls returns the number of requests received for an endpoint (or
+// Download), including ones that failed.
+func (s *Server) Calls(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[endpoint]
+}
+
+func (s *Server) newTokenLocked() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	token := hex.EncodeToString(buf)
+	s.tokens[token] = true
+	return token
+}
+
+// takeFault returns the fault to apply to a request to endpoint, if any.
+func (s *Server) takeFault(endpoint string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[endpoint]++
+
+	for i, f := range s.faults {
+		if f.Endpoint != "" && f.Endpoint != endpoint {
+			continue
+		}
+		rv := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return &rv
+	}
+	return nil
+}
+
+type apiError struct {
+	Result int    `json:"result"`
+	Error  string `json:"error"`
+}
+
+type metadata struct {
+	Name     string      `json:"name"`
+	Path     string      `json:"path"`
+	IsFolder bool        `json:"isfolder"`
+	Size     int64       `json:"size,omitempty"`
+	Modified string      `json:"modified"`
+	Contents []*metadata `json:"contents,omitempty"`
+}
+
+func fileMetadata(filename string, f *file) *metadata {
+	return &metadata{
+		Name:     path.Base(filename),
+		Path:     filename,
+		Size:     int64(len(f.data)),
+		Modified: f.modified.Format(timeFormat),
+	}
+}
+
+func folderMetadata(folder string, modified time.Time) *metadata {
+	name := path.Base(folder)
+	if folder == "/" {
+		name = "/"
+	}
+	return &metadata{
+		Name:     name,
+		Path:     folder,
+		IsFolder: true,
+		Modified: modified.Format(timeFormat),
+	}
+}
+
+// truncatingWriter sends at most limit bytes of the body and then makes
+// the handler drop the connection.
+type truncatingWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *truncatingWriter) Write(data []byte) (int, error) {
+	if len(data) > w.limit {
+		n, _ := w.ResponseWriter.Write(data[:w.limit])
+		w.limit -= n
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.limit -= n
+	return n, err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.Trim(r.URL.Path, "/")
+	if strings.HasPrefix(r.URL.Path, downloadPathPrefix) {
+		endpoint = Download
+	}
+
+	if fault := s.takeFault(endpoint); fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.HTTPStatus != 0 {
+			http.Error(w, http.StatusText(fault.HTTPStatus), fault.HTTPStatus)
+			return
+		}
+		if fault.Result != 0 {
+			writeJSON(w, apiError{Result: fault.Result, Error: fault.Message})
+			return
+		}
+		if fault.TruncateAfter > 0 {
+			w = &truncatingWriter{ResponseWriter: w, limit: fault.TruncateAfter}
+		}
+	}
+
+	if endpoint == Download {
+		s.serveDownload(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, apiError{Result: ResultInternalError, Error: fmt.Sprintf("Bad request: %v", err)})
+		return
+	}
+
+	if endpoint == "userinfo" && r.Form.Get("getauth") == "1" {
+		s.serveLogin(w, r)
+		return
+	}
+
+	handlers := map[string]func(http.ResponseWriter, *http.Request) (interface{}, *apiError){
+		"userinfo":     s.serveUserinfo,
+		"listfolder":   s.serveListfolder,
+		"uploadfile":   s.serveUploadfile,
+		"checksumfile": s.serveChecksumfile,
+		"stat":         s.serveStat,
+		"deletefile":   s.serveDeletefile,
+		"getfilelink":  s.serveGetfilelink,
+	}
+	handler, ok := handlers[endpoint]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if apiErr := s.checkAuth(r); apiErr != nil {
+		writeJSON(w, apiErr)
+		return
+	}
+
+	resp, apiErr := handler(w, r)
+	if apiErr != nil {
+		writeJSON(w, apiErr)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func (s *Server) checkAuth(r *http.Request) *apiError {
+	token := r.Form.Get("auth")
+	if token == "" {
+		return &apiError{Result: ResultLoginRequired, Error: "Log in required."}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.tokens[token] {
+		return &apiError{Result: ResultInvalidAccessToken, Error: "Invalid 'access_token' provided."}
+	}
+	return nil
+}
+
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Form.Get("username") != s.username || r.Form.Get("password") != s.password {
+		writeJSON(w, apiError{Result: ResultLoginFailed, Error: "Log in failed."})
+		return
+	}
+
+	s.mu.Lock()
+	token := s.newTokenLocked()
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"result": 0,
+		"email":  s.username,
+		"auth":   token,
+	})
+}
+
+func (s *Server) serveUserinfo(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var used int64
+	for _, f := range s.files {
+		used += int64(len(f.data))
+	}
+
+	return map[string]interface{}{
+		"result":    0,
+		"email":     s.username,
+		"quota":     s.quota,
+		"usedquota": used,
+	}, nil
+}
+
+// pathArg returns the cleaned "path" argument of a request.
+func pathArg(r *http.Request) (string, *apiError) {
+	p := r.Form.Get("path")
+	if !strings.HasPrefix(p, "/") {
+		return "", &apiError{Result: ResultNoFullPath, Error: "No full path or name/folderid provided."}
+	}
+	return path.Clean(p), nil
+}
+
+func (s *Server) serveListfolder(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	folder, apiErr := pathArg(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	noFiles := r.Form.Get("nofiles") == "1"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	modified, ok := s.folders[folder]
+	if !ok {
+		return nil, &apiError{Result: ResultDirectoryNotExist, Error: "Directory does not exist."}
+	}
+
+	rv := folderMetadata(folder, modified)
+	rv.Contents = []*metadata{}
+	for subfolder, modified := range s.folders {
+		if subfolder != "/" && path.Dir(subfolder) == folder {
+			rv.Contents = append(rv.Contents, folderMetadata(subfolder, modified))
+		}
+	}
+	if !noFiles {
+		for filename, f := range s.files {
+			if path.Dir(filename) == folder {
+				rv.Contents = append(rv.Contents, fileMetadata(filename, f))
+			}
+		}
+	}
+	sort.Slice(rv.Contents, func(i, j int) bool {
+		return rv.Contents[i].Name < rv.Contents[j].Name
+	})
+
+	return map[string]interface{}{
+		"result":   0,
+		"metadata": rv,
+	}, nil
+}
+
+func (s *Server) serveUploadfile(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	folder, apiErr := pathArg(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	name := r.Form.Get("filename")
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return nil, &apiError{Result: ResultInvalidName, Error: "Invalid file/folder name."}
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		// With nopartial=1 nothing is stored; without it pcloud would
+		// keep the partial upload, which the client never asks for.
+		return nil, &apiError{Result: ResultInternalError, Error: fmt.Sprintf("Internal upload error: %v", err)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.folders[folder]; !ok {
+		return nil, &apiError{Result: ResultDirectoryNotExist, Error: "Directory does not exist."}
+	}
+
+	filename := path.Join(folder, name)
+	if s.quota > 0 {
+		var used int64
+		for otherFilename, f := range s.files {
+			if otherFilename != filename {
+				used += int64(len(f.data))
+			}
+		}
+		if used+int64(len(data)) > s.quota {
+			return nil, &apiError{Result: ResultOverQuota, Error: "User is over quota."}
+		}
+	}
+
+	f := &file{data: data, modified: time.Now()}
+	s.files[filename] = f
+
+	return map[string]interface{}{
+		"result":   0,
+		"metadata": []*metadata{fileMetadata(filename, f)},
+	}, nil
+}
+
+// lookupFileLocked returns the file named by the "path" argument. The caller
+// must hold s.mu.
+func (s *Server) lookupFileLocked(r *http.Request) (string, *file, *apiError) {
+	filename, apiErr := pathArg(r)
+	if apiErr != nil {
+		return "", nil, apiErr
+	}
+	if _, ok := s.folders[path.Dir(filename)]; !ok {
+		return "", nil, &apiError{Result: ResultDirectoryNotExist, Error: "Directory does not exist."}
+	}
+	f, ok := s.files[filename]
+	if !ok {
+		return "", nil, &apiError{Result: ResultFileNotFound, Error: "File not found."}
+	}
+	return filename, f, nil
+}
+
+func (s *Server) serveChecksumfile(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filename, f, apiErr := s.lookupFileLocked(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	sha1sum := sha1.Sum(f.data)
+	md5sum := md5.Sum(f.data)
+	return map[string]interface{}{
+		"result":   0,
+		"sha1":     hex.EncodeToString(sha1sum[:]),
+		"md5":      hex.EncodeToString(md5sum[:]),
+		"metadata": fileMetadata(filename, f),
+	}, nil
+}
+
+func (s *Server) serveStat(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, apiErr := pathArg(r); apiErr == nil {
+		if modified, ok := s.folders[p]; ok {
+			return map[string]interface{}{
+				"result":   0,
+				"metadata": folderMetadata(p, modified),
+			}, nil
+		}
+	}
+
+	filename, f, apiErr := s.lookupFileLocked(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return map[string]interface{}{
+		"result":   0,
+		"metadata": fileMetadata(filename, f),
+	}, nil
+}
+
+func (s *Server) serveDeletefile(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filename, f, apiErr := s.lookupFileLocked(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	delete(s.files, filename)
+
+	return map[string]interface{}{
+		"result":   0,
+		"metadata": fileMetadata(filename, f),
+	}, nil
+}
+
+func (s *Server) serveGetfilelink(w http.ResponseWriter, r *http.Request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filename, _, apiErr := s.lookupFileLocked(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	linkID := hex.EncodeToString(buf)
+	s.links[linkID] = filename
+
+	return map[string]interface{}{
+		"result":  0,
+		"path":    downloadPathPrefix + linkID + "/" + path.Base(filename),
+		"hosts":   []string{r.Host},
+		"expires": time.Now().Add(time.Hour).Format(timeFormat),
+	}, nil
+}
+
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request) {
+	linkID := strings.SplitN(strings.TrimPrefix(r.URL.Path, downloadPathPrefix), "/", 2)[0]
+
+	s.mu.Lock()
+	filename, ok := s.links[linkID]
+	var f *file
+	if ok {
+		f, ok = s.files[filename]
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.data)))
+	w.Write(f.data)
+}
@@ -0,0 +1,113 @@
+package pcloud
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+}
+
+// permanentError marks an error that must not be retried regardless of
+// its classification, e.g. because a lower layer already retried it.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// jitter returns a random duration in [d/2, d).
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
+
+// withRetries calls f until it succeeds, fails with an error that is not
+// retryable, runs out of attempts, or until ctx would expire before the
+// next attempt.
+func (s *Storage) withRetries(ctx context.Context, fields logrus.Fields, f func() error) error {
+	policy := s.retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil {
+			if attempt > 1 {
+				logrus.WithFields(fields).WithFields(logrus.Fields{
+					"attempts": attempt,
+				}).Infof("pcloud operation succeeded after retrying")
+			}
+			return nil
+		}
+
+		var pe permanentError
+		if errors.As(err, &pe) {
+			return pe.err
+		}
+
+		giveUp := func(reason string) error {
+			logrus.WithFields(fields).WithFields(logrus.Fields{
+				"attempts": attempt,
+			}).Errorf("pcloud operation failed (%s): %v", reason, err)
+			return err
+		}
+
+		if !IsRetryable(err) {
+			if attempt > 1 {
+				return giveUp("permanent error")
+			}
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			return giveUp("out of attempts")
+		}
+
+		delay := jitter(backoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return giveUp("deadline too close to retry")
+		}
+
+		logrus.WithFields(fields).WithFields(logrus.Fields{
+			"attempt": attempt,
+			"delay":   delay,
+		}).Warningf("pcloud operation failed; retrying: %v", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return giveUp("context done")
+		}
+
+		backoff = time.Duration(float64(backoff) * policy.Multiplier)
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package plainhashes computes and checks conventional (unkeyed) hashes of
+// plaintext, as recorded in pb.Hashes. Unlike deduhash IDs, these can be
+// compared with the output of tools like sha256sum.
+package plainhashes
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+var (
+	Mismatch = errors.New("Hash mismatch")
+)
+
+// MismatchError is returned by Verify. It matches Mismatch with errors.Is.
+type MismatchError struct {
+	Hash string
+	Got  []byte
+	Want []byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s is %s (wanted %s)", e.Hash, hex.EncodeToString(e.Got), hex.EncodeToString(e.Want))
+}
+
+func (e *MismatchError) Is(target error) bool {
+	return target == Mismatch
+}
+
+// Writer computes all the hashes of what is written to it.
+type Writer struct {
+	sha1   hash.Hash
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+func NewWriter() *Writer {
+	return &Writer{
+		sha1:   sha1.New(),
+		md5:    md5.New(),
+		sha256: sha256.New(),
+	}
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	w.sha1.Write(data)
+	w.md5.Write(data)
+	w.sha256.Write(data)
+	return len(data), nil
+}
+
+// Hashes returns the hashes of what has been written so far.
+func (w *Writer) Hashes() *pb.Hashes {
+	return &pb.Hashes{
+		Sha1:   w.sha1.Sum(nil),
+		Md5:    w.md5.Sum(nil),
+		Sha256: w.sha256.Sum(nil),
+	}
+}
+
+// Sum returns the hashes of data.
+func Sum(data []byte) *pb.Hashes {
+	w := NewWriter()
+	w.Write(data)
+	return w.Hashes()
+}
+
+// Verify checks the hashes in got against those in want. Hashes missing
+// from want (such as SHA-256 in chunks packed before it was recorded) are
+// not checked.
+func Verify(want, got *pb.Hashes) error {
+	if want == nil {
+		return nil
+	}
+	for _, h := range []struct {
+		name      string
+		want, got []byte
+	}{
+		{"SHA-1", want.Sha1, got.Sha1},
+		{"MD5", want.Md5, got.Md5},
+		{"SHA-256", want.Sha256, got.Sha256},
+	} {
+		if len(h.want) > 0 && !bytes.Equal(h.want, h.got) {
+			return &MismatchError{Hash: h.name, Got: h.got, Want: h.want}
+		}
+	}
+	return nil
+}
+
+// Lines formats the hashes present in hashes as lines in the BSD-style
+// format understood by "sha256sum -c" and similar tools, naming the given
+// file.
+func Lines(hashes *pb.Hashes, filename string) []string {
+	if hashes == nil {
+		return nil
+	}
+	var rv []string
+	for _, h := range []struct {
+		tag    string
+		digest []byte
+	}{
+		{"SHA256", hashes.Sha256},
+		{"SHA1", hashes.Sha1},
+		{"MD5", hashes.Md5},
+	} {
+		if len(h.digest) > 0 {
+			rv = append(rv, fmt.Sprintf("%s (%s) = %s", h.tag, filename, hex.EncodeToString(h.digest)))
+		}
+	}
+	return rv
+}
@@ -0,0 +1,404 @@
+// Package s3store stores blobs in a bucket on an S3-compatible object
+// storage service (AWS S3, MinIO, Garage, ...), using signature version 4.
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/blobstore"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// Blobs larger than multipartThreshold are uploaded in parts of
+// multipartPartSize. These are variables so that tests can make them small.
+var (
+	multipartThreshold = 64 * 1024 * 1024
+	multipartPartSize  = 16 * 1024 * 1024
+)
+
+const (
+	maxErrorBodySize = 64 * 1024
+)
+
+var _ blobstore.BlobStore = &Storage{}
+
+type Storage struct {
+	creds    *pb.S3Credentials
+	endpoint *url.URL
+	prefix   string
+	client   *http.Client
+}
+
+type s3Error struct {
+	status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 HTTP error %d", e.status)
+	}
+	return fmt.Sprintf("S3 error %d %s: %s", e.status, e.Code, e.Message)
+}
+
+func makeS3Error(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return os.ErrNotExist
+	}
+	rv := s3Error{status: resp.StatusCode}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err == nil && len(data) > 0 {
+		xml.Unmarshal(data, &rv)
+	}
+	if rv.Code == "NoSuchKey" {
+		return os.ErrNotExist
+	}
+	return rv
+}
+
+func (s *Storage) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if s.creds.VirtualHostedStyle {
+		u.Host = s.creds.Bucket + "." + u.Host
+	} else {
+		path += "/" + s.creds.Bucket
+	}
+	if key != "" {
+		path += "/" + key
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	if query != nil {
+		u.RawQuery = canonicalQueryString(query)
+	}
+	return &u
+}
+
+func (s *Storage) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *Storage) do(ctx context.Context, method string, u *url.URL, body []byte, contentType string) (*http.Response, error) {
+	var bodyReader io.Reader
+	payloadHash := emptyBodySHA256
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+		payloadHash = sha256Hex(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.signRequest(req, payloadHash, time.Now())
+
+	logrus.WithFields(logrus.Fields{
+		"method": method,
+		"host":   u.Host,
+		"path":   u.Path,
+	}).Debugf("Calling S3")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s %q failed: %v", method, u.Path, err)
+	}
+	return resp, nil
+}
+
+// doExpectOK performs a request and returns the response body, or an
+// error if the response status is not 2xx.
+func (s *Storage) doExpectOK(ctx context.Context, method string, u *url.URL, body []byte, contentType string) (*http.Response, []byte, error) {
+	resp, err := s.do(ctx, method, u, body, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, makeS3Error(resp)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("S3 %s %q failed: Read error: %v", method, u.Path, err)
+	}
+	return resp, data, nil
+}
+
+func (s *Storage) Put(ctx context.Context, name string, data []byte) error {
+	if _, err := s.Stat(ctx, name); err == nil {
+		return blobstore.AlreadyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	t0 := time.Now()
+
+	if len(data) > multipartThreshold {
+		if err := s.putMultipart(ctx, name, data); err != nil {
+			return err
+		}
+	} else {
+		if _, _, err := s.doExpectOK(ctx, "PUT", s.objectURL(s.key(name), nil), data, "application/octet-stream"); err != nil {
+			return err
+		}
+	}
+
+	dur := time.Since(t0)
+	speed := float64(len(data)) / dur.Seconds()
+	logrus.WithFields(logrus.Fields{
+		"size":     len(data),
+		"duration": dur,
+		"speed":    speed,
+	}).Infof("Uploaded file %q", name)
+	return nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+func (s *Storage) putMultipart(ctx context.Context, name string, data []byte) error {
+	key := s.key(name)
+
+	_, body, err := s.doExpectOK(ctx, "POST", s.objectURL(key, url.Values{"uploads": {""}}), nil, "")
+	if err != nil {
+		return fmt.Errorf("Failed to initiate multipart upload of %q: %v", name, err)
+	}
+	initResult := initiateMultipartUploadResult{}
+	if err := xml.Unmarshal(body, &initResult); err != nil {
+		return fmt.Errorf("Failed to initiate multipart upload of %q: XML decode error: %v", name, err)
+	}
+	uploadID := initResult.UploadID
+	if uploadID == "" {
+		return fmt.Errorf("Failed to initiate multipart upload of %q: no upload ID returned", name)
+	}
+
+	abort := func() {
+		if _, _, err := s.doExpectOK(context.Background(), "DELETE", s.objectURL(key, url.Values{"uploadId": {uploadID}}), nil, ""); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"filename":  name,
+				"upload_id": uploadID,
+			}).Warningf("Failed to abort multipart upload: %v", err)
+		}
+	}
+
+	var parts []completedPart
+	for offset := 0; offset < len(data); offset += multipartPartSize {
+		end := offset + multipartPartSize
+		if end > len(data) {
+			end = len(data)
+		}
+		partNumber := len(parts) + 1
+
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadID},
+		}
+		resp, _, err := s.doExpectOK(ctx, "PUT", s.objectURL(key, query), data[offset:end], "")
+		if err != nil {
+			abort()
+			return fmt.Errorf("Failed to upload part %d of %q: %v", partNumber, name, err)
+		}
+
+		parts = append(parts, completedPart{
+			PartNumber: partNumber,
+			ETag:       resp.Header.Get("ETag"),
+		})
+	}
+
+	completion, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		abort()
+		return err
+	}
+
+	_, body, err = s.doExpectOK(ctx, "POST", s.objectURL(key, url.Values{"uploadId": {uploadID}}), completion, "application/xml")
+	if err != nil {
+		abort()
+		return fmt.Errorf("Failed to complete multipart upload of %q: %v", name, err)
+	}
+
+	// CompleteMultipartUpload can fail with a 200 status and an error body.
+	errResult := s3Error{status: http.StatusOK}
+	if xml.Unmarshal(body, &errResult) == nil && errResult.Code != "" {
+		abort()
+		return fmt.Errorf("Failed to complete multipart upload of %q: %v", name, errResult)
+	}
+
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, name string) ([]byte, error) {
+	t0 := time.Now()
+	_, data, err := s.doExpectOK(ctx, "GET", s.objectURL(s.key(name), nil), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	dur := time.Since(t0)
+	logrus.WithFields(logrus.Fields{
+		"filename": name,
+		"size":     len(data),
+		"speed":    float64(len(data)) / dur.Seconds(),
+		"duration": dur,
+	}).Infof("Download from S3 successful")
+	return data, nil
+}
+
+func (s *Storage) Stat(ctx context.Context, name string) (*blobstore.BlobInfo, error) {
+	resp, _, err := s.doExpectOK(ctx, "HEAD", s.objectURL(s.key(name), nil), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &blobstore.BlobInfo{
+		Name: name,
+		Size: resp.ContentLength,
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		t, err := http.ParseTime(lm)
+		if err != nil {
+			return nil, fmt.Errorf("Bad Last-Modified %q for %q: %v", lm, name, err)
+		}
+		rv.Modified = t
+	}
+	return rv, nil
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]blobstore.BlobInfo, error) {
+	var rv []blobstore.BlobInfo
+
+	continuationToken := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {s.key(prefix)},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		_, body, err := s.doExpectOK(ctx, "GET", s.objectURL("", query), nil, "")
+		if err != nil {
+			return nil, err
+		}
+
+		result := listBucketResult{}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("S3 list failed: XML decode error: %v", err)
+		}
+
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, s.prefix)
+			if strings.Contains(name, "/") {
+				continue
+			}
+			rv = append(rv, blobstore.BlobInfo{
+				Name:     name,
+				Size:     obj.Size,
+				Modified: obj.LastModified,
+			})
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		if result.NextContinuationToken == "" {
+			return nil, fmt.Errorf("S3 list failed: truncated result without continuation token")
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	return rv, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, name string) error {
+	// S3 does not report whether a deleted object existed.
+	if _, err := s.Stat(ctx, name); err != nil {
+		return err
+	}
+
+	if _, _, err := s.doExpectOK(ctx, "DELETE", s.objectURL(s.key(name), nil), nil, ""); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"filename": name,
+	}).Infof("Deleted file %q", name)
+	return nil
+}
+
+func (s *Storage) checkBucketExists(ctx context.Context) error {
+	_, _, err := s.doExpectOK(ctx, "HEAD", s.objectURL("", nil), nil, "")
+	return err
+}
+
+// New connects to the given storage. If httpClient is nil, a default
+// client is used.
+func New(ctx context.Context, creds *pb.S3Credentials, prefix string, httpClient *http.Client) (*Storage, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	endpoint, err := url.Parse(creds.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid S3 endpoint %q: %v", creds.Endpoint, err)
+	}
+	if endpoint.Scheme != "https" && endpoint.Scheme != "http" {
+		return nil, fmt.Errorf("Invalid S3 endpoint %q: scheme must be http or https", creds.Endpoint)
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	store := &Storage{
+		creds:    creds,
+		endpoint: endpoint,
+		prefix:   prefix,
+		client:   httpClient,
+	}
+
+	if err := store.checkBucketExists(ctx); err != nil {
+		return nil, fmt.Errorf("Unable to access S3 bucket %q at %q: %v", creds.Bucket, creds.Endpoint, err)
+	}
+
+	return store, nil
+}
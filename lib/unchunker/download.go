@@ -0,0 +1,106 @@
+package unchunker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// SuggestedFilename returns the name to save a downloaded chunk as in an
+// output directory: the suggested filename recorded at upload, if it is a
+// plain filename, and the chunk ID otherwise.
+func SuggestedFilename(chunkId string, header *pb.Header) string {
+	name := header.Private.GetOptionalMetadata().GetSuggestedFilename()
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) || strings.ContainsAny(name, "/\\") {
+		return chunkId
+	}
+	return name
+}
+
+// DownloadToDir downloads a chunk into a temporary file in dir, also
+// writing the plaintext to hashes, and once it has been verified, renames
+// it to its suggested filename and restores its modification time and
+// permissions. It returns the name of the file.
+//
+// Existing files are not overwritten: the filename is claimed by creating
+// it exclusively before the rename. Without recorded permissions, the file
+// gets the default ones (0666 less the umask).
+func (u *Unchunker) DownloadToDir(ctx context.Context, chunkId, dir string, hashes io.Writer) (string, error) {
+	f, err := ioutil.TempFile(dir, ".dedu-download-")
+	if err != nil {
+		return "", err
+	}
+	tempName := f.Name()
+	defer os.Remove(tempName)
+
+	w := bufio.NewWriter(f)
+	header, err := u.WriteChunk(ctx, chunkId, io.MultiWriter(w, hashes))
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(dir, SuggestedFilename(chunkId, header))
+	placeholder, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return "", fmt.Errorf("Not overwriting existing file %q with chunk %q", filename, chunkId)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := finishDownload(chunkId, header, tempName, placeholder); err != nil {
+		os.Remove(filename)
+		return "", err
+	}
+	return filename, nil
+}
+
+// finishDownload sets the permissions and modification time of the
+// downloaded file tempName, and renames it over placeholder, which was
+// created with the default permissions.
+func finishDownload(chunkId string, header *pb.Header, tempName string, placeholder *os.File) error {
+	info, err := placeholder.Stat()
+	if closeErr := placeholder.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	metadata := header.Private.GetOptionalMetadata()
+
+	mode := info.Mode().Perm()
+	if metadata.GetMode() != 0 {
+		mode = os.FileMode(metadata.GetMode()).Perm()
+	}
+	if err := os.Chmod(tempName, mode); err != nil {
+		return err
+	}
+
+	if ts := metadata.GetModificationTimestamp(); ts != "" {
+		mtime, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			logrus.Warnf("Chunk %q has invalid modification timestamp %q: %v", chunkId, ts, err)
+		} else if err := os.Chtimes(tempName, mtime, mtime); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tempName, placeholder.Name())
+}
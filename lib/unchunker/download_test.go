@@ -0,0 +1,178 @@
+package unchunker
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/dedu/lib/deduchunk"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// putTestChunk packs data with the given metadata and stores it.
+func putTestChunk(t *testing.T, u *Unchunker, data []byte, metadata *pb.ChunkMetadata) string {
+	t.Helper()
+
+	chunkId, err := u.Hasher.ComputeHash(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := u.Packer.Pack(data, &deduchunk.ExtraData{Metadata: metadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Storage.Put(context.Background(), chunkId, packed); err != nil {
+		t.Fatal(err)
+	}
+	return chunkId
+}
+
+// checkOnlyFiles checks that dir contains exactly the named files.
+func checkOnlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, info := range infos {
+		got = append(got, info.Name())
+	}
+	if len(got) != len(names) {
+		t.Errorf("%q contains %v, want %v", dir, got, names)
+		return
+	}
+	for i := range names {
+		if got[i] != names[i] {
+			t.Errorf("%q contains %v, want %v", dir, got, names)
+			return
+		}
+	}
+}
+
+func TestDownloadToDirRestoresMetadata(t *testing.T) {
+	u, _ := newTestUnchunker(t)
+	data := []byte("the contents of the file")
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.UTC)
+	chunkId := putTestChunk(t, u, data, &pb.ChunkMetadata{
+		SuggestedFilename:     "notes.txt",
+		ModificationTimestamp: mtime.Format(time.RFC3339Nano),
+		Mode:                  0750,
+	})
+
+	dir := t.TempDir()
+	hashes := bytes.NewBuffer(nil)
+	filename, err := u.DownloadToDir(context.Background(), chunkId, dir, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filename != filepath.Join(dir, "notes.txt") {
+		t.Errorf("DownloadToDir() = %q, want notes.txt in %q", filename, dir)
+	}
+	if !bytes.Equal(hashes.Bytes(), data) {
+		t.Errorf("DownloadToDir() wrote %q to the hashes", hashes.Bytes())
+	}
+
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("downloaded file contains %q, want %q", got, data)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("downloaded file has mode %v, want %v", info.Mode().Perm(), os.FileMode(0750))
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("downloaded file has modification time %v, want %v", info.ModTime(), mtime)
+	}
+	checkOnlyFiles(t, dir, "notes.txt")
+}
+
+func TestDownloadToDirDefaultMode(t *testing.T) {
+	u, _ := newTestUnchunker(t)
+	chunkId := putTestChunk(t, u, []byte("data"), &pb.ChunkMetadata{SuggestedFilename: "plain.txt"})
+
+	dir := t.TempDir()
+	filename, err := u.DownloadToDir(context.Background(), chunkId, dir, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mode a file created normally would get under the current umask.
+	reference := filepath.Join(t.TempDir(), "reference")
+	if err := ioutil.WriteFile(reference, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.Stat(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != want.Mode().Perm() {
+		t.Errorf("downloaded file has mode %v, want %v", info.Mode().Perm(), want.Mode().Perm())
+	}
+}
+
+func TestDownloadToDirDoesNotOverwrite(t *testing.T) {
+	u, _ := newTestUnchunker(t)
+	chunkId := putTestChunk(t, u, []byte("new contents"), &pb.ChunkMetadata{SuggestedFilename: "notes.txt", Mode: 0600})
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "notes.txt")
+	if err := ioutil.WriteFile(existing, []byte("precious"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.DownloadToDir(context.Background(), chunkId, dir, ioutil.Discard); err == nil {
+		t.Errorf("DownloadToDir() over an existing file succeeded")
+	}
+
+	got, err := ioutil.ReadFile(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "precious" {
+		t.Errorf("existing file now contains %q", got)
+	}
+	checkOnlyFiles(t, dir, "notes.txt")
+}
+
+func TestDownloadToDirRejectsUnsafeFilenames(t *testing.T) {
+	u, _ := newTestUnchunker(t)
+
+	for i, name := range []string{"", ".", "..", "../x", "../../x", "/tmp/x", "sub/x", "sub\\x"} {
+		// Distinct data, so that each chunk has its own ID.
+		chunkId := putTestChunk(t, u, []byte{byte(i)}, &pb.ChunkMetadata{SuggestedFilename: name})
+
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "out")
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		filename, err := u.DownloadToDir(context.Background(), chunkId, dir, ioutil.Discard)
+		if err != nil {
+			t.Errorf("suggested filename %q: DownloadToDir() = %v", name, err)
+			continue
+		}
+		if filename != filepath.Join(dir, chunkId) {
+			t.Errorf("suggested filename %q: saved as %q, want the chunk ID", name, filename)
+		}
+		checkOnlyFiles(t, dir, chunkId)
+		checkOnlyFiles(t, parent, "out")
+	}
+}
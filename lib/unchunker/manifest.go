@@ -0,0 +1,125 @@
+package unchunker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/steinarvk/dedu/lib/deduchunk"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// manifest is a virtual chunk, along with the offsets at which the
+// content of each of its references starts.
+type manifest struct {
+	vc     *pb.VirtualChunk
+	depth  int32
+	starts []int64
+}
+
+func badManifest(vc *pb.VirtualChunk, format string, args ...interface{}) error {
+	return &deduchunk.Error{
+		ChunkId: vc.ChunkId,
+		Err:     fmt.Errorf("%w: "+format, append([]interface{}{deduchunk.ErrMalformedHeader}, args...)...),
+	}
+}
+
+// newManifest checks that the references of vc add up, and that it is
+// within the limits on size and depth.
+func newManifest(vc *pb.VirtualChunk) (*manifest, error) {
+	depth := deduchunk.ManifestDepth(vc)
+	if depth > deduchunk.MaxManifestDepth {
+		return nil, badManifest(vc, "depth %d exceeds the maximum of %d", depth, deduchunk.MaxManifestDepth)
+	}
+	if len(vc.Chunk) > deduchunk.MaxManifestEntries {
+		return nil, badManifest(vc, "%d references exceed the maximum of %d", len(vc.Chunk), deduchunk.MaxManifestEntries)
+	}
+
+	starts := make([]int64, len(vc.Chunk))
+	var offset int64
+	for i, ref := range vc.Chunk {
+		if ref.Length <= 0 {
+			return nil, badManifest(vc, "subchunk %q has bad length %d", ref.Hash, ref.Length)
+		}
+		if ref.Virtual && depth == 1 {
+			return nil, badManifest(vc, "subchunk %q is virtual at depth 1", ref.Hash)
+		}
+		starts[i] = offset
+		offset += ref.Length
+	}
+	if offset != vc.TotalLength {
+		return nil, badManifest(vc, "subchunks total %d bytes (wanted %d)", offset, vc.TotalLength)
+	}
+
+	return &manifest{
+		vc:     vc,
+		depth:  depth,
+		starts: starts,
+	}, nil
+}
+
+// checkIntermediate verifies the chunk ID of an intermediate virtual
+// chunk, which is a hash of its references rather than its content.
+func (u *Unchunker) checkIntermediate(vc *pb.VirtualChunk) error {
+	hasher, err := u.Hasher.ForHash(vc.ChunkId)
+	if err != nil {
+		return badManifest(vc, "%v", err)
+	}
+	chunkId, err := deduchunk.IntermediateChunkId(hasher, vc)
+	if err != nil {
+		return err
+	}
+	if chunkId != vc.ChunkId {
+		return &deduchunk.Error{
+			ChunkId: vc.ChunkId,
+			Err:     fmt.Errorf("%w: references hash to %q", deduchunk.ErrHashMismatch, chunkId),
+		}
+	}
+	return nil
+}
+
+// fetchManifest fetches the intermediate virtual chunk referenced by ref
+// from parent, and checks it against the reference.
+func (u *Unchunker) fetchManifest(ctx context.Context, parent *manifest, ref *pb.ChunkReference) (*manifest, error) {
+	_, headers, err := u.fetch(ctx, ref.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	vc := headers.Private.VirtualChunk
+	if vc == nil || !vc.Intermediate {
+		return nil, badManifest(parent.vc, "subchunk %q is not an intermediate virtual chunk", ref.Hash)
+	}
+	if vc.TotalLength != ref.Length {
+		return nil, badManifest(parent.vc, "subchunk %q has length %d (wanted %d)", ref.Hash, vc.TotalLength, ref.Length)
+	}
+	if err := u.checkIntermediate(vc); err != nil {
+		return nil, err
+	}
+
+	m, err := newManifest(vc)
+	if err != nil {
+		return nil, err
+	}
+	if m.depth >= parent.depth {
+		return nil, badManifest(parent.vc, "subchunk %q has depth %d (wanted less than %d)", ref.Hash, m.depth, parent.depth)
+	}
+	return m, nil
+}
+
+// fetchData fetches the data chunk referenced by ref from parent, and
+// checks its length against the reference.
+func (u *Unchunker) fetchData(ctx context.Context, parent *manifest, ref *pb.ChunkReference) ([]byte, error) {
+	plaintext, headers, err := u.fetch(ctx, ref.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers.Private.VirtualChunk != nil {
+		return nil, badManifest(parent.vc, "subchunk %q is virtual, but not marked as such", ref.Hash)
+	}
+	if int64(len(plaintext)) != ref.Length {
+		return nil, badManifest(parent.vc, "subchunk %q has length %d (wanted %d)", ref.Hash, len(plaintext), ref.Length)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,190 @@
+package unchunker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/deduchunk"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+const (
+	DefaultCacheSize = 4
+
+	// manifestCacheSize is the number of intermediate virtual chunks kept
+	// in memory by a ReaderAt.
+	manifestCacheSize = 4 * deduchunk.MaxManifestDepth
+)
+
+// ReaderAt gives random access to the contents of a virtual chunk. Only
+// the subchunks covering the requested ranges are downloaded (along with
+// the intermediate virtual chunks leading to them, if nested), and the
+// most recently used ones are kept in memory.
+//
+// Each subchunk is verified against its reference when fetched, but the
+// hash of the whole content is never checked, since that would require
+// reading all of it.
+type ReaderAt struct {
+	ctx       context.Context
+	u         *Unchunker
+	root      *manifest
+	cache     *lruCache
+	manifests *lruCache
+}
+
+var _ io.ReaderAt = &ReaderAt{}
+
+// NewReaderAt creates a ReaderAt caching up to cacheSize subchunks (or
+// DefaultCacheSize, if cacheSize is zero).
+func (u *Unchunker) NewReaderAt(ctx context.Context, vc *pb.VirtualChunk, cacheSize int) (*ReaderAt, error) {
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+
+	if vc.Intermediate {
+		if err := u.checkIntermediate(vc); err != nil {
+			return nil, err
+		}
+	}
+	root, err := newManifest(vc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReaderAt{
+		ctx:       ctx,
+		u:         u,
+		root:      root,
+		cache:     newLRUCache(cacheSize),
+		manifests: newLRUCache(manifestCacheSize),
+	}, nil
+}
+
+// Open creates a ReaderAt over the chunk with the given ID, which may be
+// virtual or not.
+func (u *Unchunker) Open(ctx context.Context, chunkId string, cacheSize int) (*ReaderAt, error) {
+	plaintext, headers, err := u.fetch(ctx, chunkId)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers.Private.VirtualChunk != nil {
+		return u.NewReaderAt(ctx, headers.Private.VirtualChunk, cacheSize)
+	}
+
+	// Present a plain chunk as a virtual chunk consisting of itself.
+	vc := &pb.VirtualChunk{
+		ChunkId:     chunkId,
+		TotalLength: int64(len(plaintext)),
+	}
+	if len(plaintext) > 0 {
+		vc.Chunk = []*pb.ChunkReference{{Hash: chunkId, Length: int64(len(plaintext))}}
+	}
+	rv, err := u.NewReaderAt(ctx, vc, cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	rv.cache.put(chunkId, plaintext)
+	return rv, nil
+}
+
+// NewReadSeeker is like NewReaderAt, but returns an io.ReadSeeker
+// positioned at the start of the content.
+func (u *Unchunker) NewReadSeeker(ctx context.Context, vc *pb.VirtualChunk, cacheSize int) (io.ReadSeeker, error) {
+	r, err := u.NewReaderAt(ctx, vc, cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(r, 0, r.Size()), nil
+}
+
+// Size returns the length of the content.
+func (r *ReaderAt) Size() int64 {
+	return r.root.vc.TotalLength
+}
+
+func (r *ReaderAt) manifest(parent *manifest, ref *pb.ChunkReference) (*manifest, error) {
+	if m, ok := r.manifests.get(ref.Hash); ok {
+		return m.(*manifest), nil
+	}
+
+	m, err := r.u.fetchManifest(r.ctx, parent, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	r.manifests.put(ref.Hash, m)
+	return m, nil
+}
+
+func (r *ReaderAt) subchunk(parent *manifest, ref *pb.ChunkReference) ([]byte, error) {
+	if plaintext, ok := r.cache.get(ref.Hash); ok {
+		return plaintext.([]byte), nil
+	}
+
+	plaintext, err := r.u.fetchData(r.ctx, parent, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.put(ref.Hash, plaintext)
+	return plaintext, nil
+}
+
+// subchunkAt returns the data subchunk containing offset off, and the
+// offset at which it starts.
+func (r *ReaderAt) subchunkAt(off int64) ([]byte, int64, error) {
+	m := r.root
+	var base int64
+	for {
+		// The last reference starting at or before off.
+		i := sort.Search(len(m.starts), func(i int) bool {
+			return base+m.starts[i] > off
+		}) - 1
+		ref := m.vc.Chunk[i]
+		base += m.starts[i]
+
+		if !ref.Virtual {
+			plaintext, err := r.subchunk(m, ref)
+			return plaintext, base, err
+		}
+
+		child, err := r.manifest(m, ref)
+		if err != nil {
+			return nil, 0, err
+		}
+		m = child
+	}
+}
+
+func (r *ReaderAt) ReadAt(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative offset %d", off)
+	}
+	if off >= r.Size() {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(buf) && off < r.Size() {
+		plaintext, start, err := r.subchunkAt(off)
+		if err != nil {
+			return n, err
+		}
+
+		logrus.Debugf("Reading %d bytes at offset %d of %q from subchunk at %d", len(buf)-n, off, r.root.vc.ChunkId, start)
+
+		copied := copy(buf[n:], plaintext[off-start:])
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -1 +1,260 @@
+// Package unchunker reassembles the contents of chunks from storage.
 package unchunker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/blobstore"
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/deduhash"
+	"github.com/steinarvk/dedu/lib/plainhashes"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+type Unchunker struct {
+	Storage blobstore.BlobStore
+	Packer  *deduchunk.Packer
+	Hasher  *deduhash.Hasher
+}
+
+// chunkReader is the plaintext of a chunk being downloaded.
+type chunkReader struct {
+	io.Reader
+	body io.Closer
+}
+
+func (c *chunkReader) Close() error {
+	return c.body.Close()
+}
+
+// open starts downloading and unpacking a single chunk, streaming it from
+// storage if possible. The headers are checked against the chunk ID
+// before open returns; the plaintext is verified as it is read, so if the
+// reader returns an error the caller must discard what was read. Errors
+// from Unpack are *deduchunk.Error values naming the chunk.
+func (u *Unchunker) open(ctx context.Context, chunkId string) (*chunkReader, *pb.Header, error) {
+	body, err := blobstore.GetReader(ctx, u.Storage, chunkId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to fetch chunk %q: %w", chunkId, err)
+	}
+
+	plaintext, headers, err := u.Packer.UnpackStream(body, chunkId)
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+
+	// The reassembled content of a virtual chunk is verified against the
+	// ID it describes, so that must be the ID that was asked for.
+	if vc := headers.Private.VirtualChunk; vc != nil && vc.ChunkId != chunkId {
+		body.Close()
+		return nil, nil, &deduchunk.Error{
+			ChunkId: chunkId,
+			Err:     fmt.Errorf("%w: describes virtual chunk %q", deduchunk.ErrWrongChunk, vc.ChunkId),
+		}
+	}
+
+	logrus.Infof("Read chunk %q with headers: %v", chunkId, headers)
+
+	return &chunkReader{Reader: plaintext, body: body}, headers, nil
+}
+
+// fetch downloads, unpacks and verifies a single chunk.
+func (u *Unchunker) fetch(ctx context.Context, chunkId string) ([]byte, *pb.Header, error) {
+	r, headers, err := u.open(ctx, chunkId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, headers, nil
+}
+
+// WriteChunk writes the contents of the chunk with the given ID to w,
+// reassembling it from its subchunks if it is virtual, and returns its
+// headers. Data chunks are streamed from storage as they are written, and
+// verified at the end: if an error is returned, the caller must discard
+// what was written. See Reader for the verification of virtual chunks.
+func (u *Unchunker) WriteChunk(ctx context.Context, chunkId string, w io.Writer) (*pb.Header, error) {
+	r, headers, err := u.open(ctx, chunkId)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if headers.Private.VirtualChunk == nil {
+		if _, err := io.Copy(w, r); err != nil {
+			return nil, err
+		}
+		return headers, nil
+	}
+
+	// Read to the end, so that the (empty) plaintext is verified.
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(w, u.NewReader(ctx, headers.Private.VirtualChunk)); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// Reader streams the contents of a virtual chunk, fetching and unpacking
+// one subchunk at a time. Nested virtual chunks are read depth first, so
+// only one manifest per level is held in memory.
+//
+// Every subchunk is verified against its reference before any of its data
+// is returned. The hashes of the whole content (its chunk ID, and any
+// plaintext hashes recorded in the virtual chunk) can only be verified at
+// the end: if they do not match, Read returns an error instead of io.EOF,
+// and the caller must discard what was read. An intermediate virtual
+// chunk has no content hash; its chunk ID is checked before anything is
+// read.
+type Reader struct {
+	ctx     context.Context
+	u       *Unchunker
+	vc      *pb.VirtualChunk
+	started bool
+	stack   []*readerFrame
+	buf     []byte
+	hasher  *deduhash.Writer
+	hashes  *plainhashes.Writer
+	err     error
+}
+
+// readerFrame is a virtual chunk being read, and the index of the next
+// reference to read from it.
+type readerFrame struct {
+	m    *manifest
+	next int
+}
+
+func (u *Unchunker) NewReader(ctx context.Context, vc *pb.VirtualChunk) *Reader {
+	return &Reader{
+		ctx:    ctx,
+		u:      u,
+		vc:     vc,
+		hashes: plainhashes.NewWriter(),
+	}
+}
+
+func (r *Reader) Read(buf []byte) (int, error) {
+	for len(r.buf) == 0 && r.err == nil {
+		r.err = r.advance()
+	}
+	if len(r.buf) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(buf, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *Reader) start() error {
+	if r.vc.Intermediate {
+		if err := r.u.checkIntermediate(r.vc); err != nil {
+			return err
+		}
+		r.hasher = r.u.Hasher.NewWriter()
+	} else {
+		hasher, err := r.u.Hasher.NewWriterFor(r.vc.ChunkId)
+		if err != nil {
+			return badManifest(r.vc, "%v", err)
+		}
+		r.hasher = hasher
+	}
+	m, err := newManifest(r.vc)
+	if err != nil {
+		return err
+	}
+	r.stack = []*readerFrame{{m: m}}
+	return nil
+}
+
+// advance loads the next data subchunk into r.buf, or verifies the whole
+// content and returns io.EOF if there are no more subchunks.
+func (r *Reader) advance() error {
+	if !r.started {
+		r.started = true
+		if err := r.start(); err != nil {
+			return err
+		}
+	}
+
+	for len(r.stack) > 0 {
+		top := r.stack[len(r.stack)-1]
+		if top.next >= len(top.m.vc.Chunk) {
+			r.stack = r.stack[:len(r.stack)-1]
+			continue
+		}
+
+		ref := top.m.vc.Chunk[top.next]
+		top.next++
+
+		if ref.Virtual {
+			m, err := r.u.fetchManifest(r.ctx, top.m, ref)
+			if err != nil {
+				return err
+			}
+			r.stack = append(r.stack, &readerFrame{m: m})
+			continue
+		}
+
+		plaintext, err := r.u.fetchData(r.ctx, top.m, ref)
+		if err != nil {
+			return err
+		}
+
+		r.hasher.Write(plaintext)
+		r.hashes.Write(plaintext)
+		r.buf = plaintext
+		return nil
+	}
+
+	return r.finish()
+}
+
+func (r *Reader) finish() error {
+	if r.hasher.Size() != r.vc.TotalLength {
+		return &deduchunk.Error{
+			ChunkId: r.vc.ChunkId,
+			Err:     fmt.Errorf("%w: reconstructed content has length %d (wanted %d)", deduchunk.ErrHashMismatch, r.hasher.Size(), r.vc.TotalLength),
+		}
+	}
+	if r.vc.Intermediate {
+		return io.EOF
+	}
+
+	computedHash, err := r.hasher.Sum()
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Reconstructed content has hash %q (wanted %q) and length %d", computedHash, r.vc.ChunkId, r.hasher.Size())
+
+	if computedHash != r.vc.ChunkId {
+		return &deduchunk.Error{
+			ChunkId: r.vc.ChunkId,
+			Err:     fmt.Errorf("%w: reconstructed content has hash %q", deduchunk.ErrHashMismatch, computedHash),
+		}
+	}
+	if err := plainhashes.Verify(r.vc.PlaintextHashes, r.hashes.Hashes()); err != nil {
+		return &deduchunk.Error{
+			ChunkId: r.vc.ChunkId,
+			Err:     fmt.Errorf("%w: reconstructed content: %w", deduchunk.ErrHashMismatch, err),
+		}
+	}
+
+	return io.EOF
+}
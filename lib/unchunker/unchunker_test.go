@@ -0,0 +1,451 @@
+package unchunker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/steinarvk/dedu/lib/chunker"
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/internal/chunktest"
+	"github.com/steinarvk/dedu/lib/localstore"
+	"github.com/steinarvk/dedu/lib/uploader"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+const (
+	testChunkSize  = 100
+	testMaxEntries = 3
+)
+
+// testTree is a file uploaded as a tree of virtual chunks with at most
+// testMaxEntries references each.
+type testTree struct {
+	u     *Unchunker
+	store *localstore.Storage
+	data  []byte
+	root  string
+
+	// intermediates are the IDs of the intermediate virtual chunks, in
+	// the order they were uploaded.
+	intermediates []string
+}
+
+func newTestUnchunker(t *testing.T) (*Unchunker, *localstore.Storage) {
+	t.Helper()
+
+	packer := chunktest.NewPacker(t)
+	store := chunktest.NewStore(t)
+	return &Unchunker{
+		Storage: store,
+		Packer:  packer,
+		Hasher:  packer.Hasher,
+	}, store
+}
+
+func newTestTree(t *testing.T, length int) *testTree {
+	t.Helper()
+
+	u, store := newTestUnchunker(t)
+	tree := &testTree{u: u, store: store, data: chunktest.Data(length)}
+
+	filename := filepath.Join(t.TempDir(), "file.bin")
+	if err := ioutil.WriteFile(filename, tree.data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var virtual []string
+	up := &uploader.Uploader{
+		Chunker:            &chunker.Chunker{Hasher: u.Hasher, ChunkSize: testChunkSize},
+		Packer:             u.Packer,
+		Storage:            store,
+		MaxManifestEntries: testMaxEntries,
+		OnChunk: func(r uploader.Result) {
+			if r.Virtual {
+				virtual = append(virtual, r.ChunkId)
+			}
+		},
+	}
+	root, err := up.UploadFile(context.Background(), filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.root = root
+	for _, chunkId := range virtual {
+		if chunkId != root {
+			tree.intermediates = append(tree.intermediates, chunkId)
+		}
+	}
+	return tree
+}
+
+func (tree *testTree) get(t *testing.T, chunkId string) []byte {
+	t.Helper()
+	packed, err := tree.store.Get(context.Background(), chunkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packed
+}
+
+func (tree *testTree) replace(t *testing.T, chunkId string, packed []byte) {
+	t.Helper()
+	ctx := context.Background()
+	if err := tree.store.Delete(ctx, chunkId); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.store.Put(ctx, chunkId, packed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (tree *testTree) virtualChunk(t *testing.T, chunkId string) *pb.VirtualChunk {
+	t.Helper()
+	_, headers, err := tree.u.Packer.Unpack(tree.get(t, chunkId), chunkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers.Private.VirtualChunk == nil {
+		t.Fatalf("chunk %q is not virtual", chunkId)
+	}
+	return headers.Private.VirtualChunk
+}
+
+// checkFails checks that err is a rejection of a chunk for the given
+// reason.
+func checkFails(t *testing.T, what string, err error, want error) {
+	t.Helper()
+
+	var chunkErr *deduchunk.Error
+	if !errors.As(err, &chunkErr) {
+		t.Errorf("%s: got %v, want a chunk error", what, err)
+		return
+	}
+	if got := chunkErr.Check(); got != want {
+		t.Errorf("%s: failed check %v, want %v (error: %v)", what, got, want, err)
+	}
+}
+
+func TestWriteChunkNested(t *testing.T) {
+	for _, length := range []int{250, 900, 2700} {
+		tree := newTestTree(t, length)
+
+		buf := bytes.NewBuffer(nil)
+		if _, err := tree.u.WriteChunk(context.Background(), tree.root, buf); err != nil {
+			t.Fatalf("%d bytes: WriteChunk() = %v", length, err)
+		}
+		if !bytes.Equal(buf.Bytes(), tree.data) {
+			t.Errorf("%d bytes: WriteChunk() wrote %d bytes with the wrong content", length, buf.Len())
+		}
+	}
+}
+
+func TestReaderAtCrossesBoundaries(t *testing.T) {
+	// 27 chunks: the root references three intermediates of 900 bytes,
+	// each of which references three of 300 bytes.
+	tree := newTestTree(t, 2700-17)
+	if depth := deduchunk.ManifestDepth(tree.virtualChunk(t, tree.root)); depth != 3 {
+		t.Fatalf("root has depth %d, want 3", depth)
+	}
+
+	r, err := tree.u.Open(context.Background(), tree.root, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(tree.data)) {
+		t.Errorf("Size() = %d, want %d", r.Size(), len(tree.data))
+	}
+
+	for _, tc := range []struct {
+		off, length int
+	}{
+		{0, 1},
+		{95, 10},
+		{290, 20},
+		{895, 10},
+		{1790, 20},
+		{850, 1000},
+		{0, len(tree.data)},
+		{1799, 2},
+		{2500, 100},
+		{len(tree.data) - 1, 1},
+	} {
+		buf := make([]byte, tc.length)
+		n, err := r.ReadAt(buf, int64(tc.off))
+		if err != nil || n != tc.length {
+			t.Errorf("ReadAt(%d bytes at %d) = %d, %v", tc.length, tc.off, n, err)
+			continue
+		}
+		if !bytes.Equal(buf, tree.data[tc.off:tc.off+tc.length]) {
+			t.Errorf("ReadAt(%d bytes at %d) returned the wrong content", tc.length, tc.off)
+		}
+	}
+
+	buf := make([]byte, 50)
+	if n, err := r.ReadAt(buf, int64(len(tree.data)-10)); n != 10 || err != io.EOF || !bytes.Equal(buf[:n], tree.data[len(tree.data)-10:]) {
+		t.Errorf("ReadAt() across the end = %d, %v; want 10, EOF", n, err)
+	}
+	if n, err := r.ReadAt(buf, int64(len(tree.data))); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt() at the end = %d, %v; want 0, EOF", n, err)
+	}
+}
+
+// checkTreeRejected checks that the tree can be read by neither Reader
+// nor ReaderAt.
+func checkTreeRejected(t *testing.T, what string, tree *testTree, want error) {
+	t.Helper()
+
+	_, err := tree.u.WriteChunk(context.Background(), tree.root, ioutil.Discard)
+	checkFails(t, what+": WriteChunk()", err, want)
+
+	r, err := tree.u.Open(context.Background(), tree.root, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.ReadAt(make([]byte, len(tree.data)), 0)
+	checkFails(t, what+": ReadAt()", err, want)
+}
+
+func TestRejectsTamperedIntermediate(t *testing.T) {
+	const length = 2700
+
+	t.Run("swapped", func(t *testing.T) {
+		tree := newTestTree(t, length)
+		victim, other := tree.intermediates[0], tree.intermediates[1]
+		tree.replace(t, victim, tree.get(t, other))
+		checkTreeRejected(t, "swapped", tree, deduchunk.ErrWrongChunk)
+	})
+
+	t.Run("renamed", func(t *testing.T) {
+		tree := newTestTree(t, length)
+		victim, other := tree.intermediates[0], tree.intermediates[1]
+		tree.replace(t, victim, chunktest.RenameChunk(t, tree.u.Packer, tree.get(t, other), victim))
+		checkTreeRejected(t, "renamed", tree, deduchunk.ErrWrongChunk)
+	})
+
+	t.Run("forged", func(t *testing.T) {
+		// A chunk with the right ID but reordered references can only be
+		// caught by hashing the references.
+		tree := newTestTree(t, length)
+		victim := tree.intermediates[0]
+		vc := tree.virtualChunk(t, victim)
+		vc.Chunk[0], vc.Chunk[1] = vc.Chunk[1], vc.Chunk[0]
+		packed, err := tree.u.Packer.Pack(nil, &deduchunk.ExtraData{VirtualChunk: vc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.replace(t, victim, packed)
+		checkTreeRejected(t, "forged", tree, deduchunk.ErrHashMismatch)
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		tree := newTestTree(t, length)
+		victim := tree.intermediates[len(tree.intermediates)-1]
+		packed := tree.get(t, victim)
+		packed[len(packed)-1] ^= 1
+		tree.replace(t, victim, packed)
+		checkTreeRejected(t, "corrupted", tree, deduchunk.ErrPayloadDecrypt)
+	})
+}
+
+func TestRejectsTooDeep(t *testing.T) {
+	tree := newTestTree(t, 900)
+	vc := tree.virtualChunk(t, tree.root)
+
+	deep := *vc
+	deep.Depth = deduchunk.MaxManifestDepth + 1
+
+	_, err := io.Copy(ioutil.Discard, tree.u.NewReader(context.Background(), &deep))
+	checkFails(t, "Reader", err, deduchunk.ErrMalformedHeader)
+
+	_, err = tree.u.NewReaderAt(context.Background(), &deep, 0)
+	checkFails(t, "NewReaderAt()", err, deduchunk.ErrMalformedHeader)
+}
+
+func TestRejectsDepthNotDecreasing(t *testing.T) {
+	// The root of a 900-byte file has depth 2 and references
+	// intermediates of depth 1. A root forged to have depth 1 must not
+	// follow them.
+	tree := newTestTree(t, 900)
+	vc := tree.virtualChunk(t, tree.root)
+	vc.Depth = 1
+	for _, ref := range vc.Chunk {
+		ref.Virtual = false
+	}
+
+	_, err := io.Copy(ioutil.Discard, tree.u.NewReader(context.Background(), vc))
+	checkFails(t, "Reader", err, deduchunk.ErrMalformedHeader)
+
+	// An intermediate of the same depth as its parent is rejected too.
+	parent := tree.virtualChunk(t, tree.root)
+	child := tree.virtualChunk(t, tree.intermediates[0])
+	child.Depth = parent.Depth
+	for _, ref := range child.Chunk {
+		ref.Virtual = true
+	}
+	chunkId, err := deduchunk.IntermediateChunkId(tree.u.Hasher, child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child.ChunkId = chunkId
+	packed, err := tree.u.Packer.Pack(nil, &deduchunk.ExtraData{VirtualChunk: child})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.store.Put(context.Background(), chunkId, packed); err != nil {
+		t.Fatal(err)
+	}
+	parent.Chunk[0].Hash = chunkId
+
+	_, err = io.Copy(ioutil.Discard, tree.u.NewReader(context.Background(), parent))
+	checkFails(t, "Reader", err, deduchunk.ErrMalformedHeader)
+}
+
+// putChunk packs and stores a chunk, returning its ID.
+func putChunk(t *testing.T, u *Unchunker, chunkId string, plaintext []byte, extra *deduchunk.ExtraData) []byte {
+	t.Helper()
+
+	packed, err := u.Packer.Pack(plaintext, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Storage.Put(context.Background(), chunkId, packed); err != nil {
+		t.Fatal(err)
+	}
+	return packed
+}
+
+// putVirtualChunk stores data as subchunks of at most pieceSize bytes
+// and a virtual chunk referencing them, returning the virtual chunk and
+// its packed form.
+func putVirtualChunk(t *testing.T, u *Unchunker, data []byte, pieceSize int) (*pb.VirtualChunk, []byte) {
+	t.Helper()
+
+	chunkId, err := u.Hasher.ComputeHash(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc := &pb.VirtualChunk{ChunkId: chunkId, TotalLength: int64(len(data))}
+	for offset := 0; offset < len(data); offset += pieceSize {
+		end := offset + pieceSize
+		if end > len(data) {
+			end = len(data)
+		}
+		piece := data[offset:end]
+		hash, err := u.Hasher.ComputeHash(bytes.NewReader(piece))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Storage.Stat(context.Background(), hash); err != nil {
+			putChunk(t, u, hash, piece, nil)
+		}
+		vc.Chunk = append(vc.Chunk, &pb.ChunkReference{Hash: hash, Length: int64(len(piece))})
+	}
+	return vc, putChunk(t, u, chunkId, nil, &deduchunk.ExtraData{VirtualChunk: vc})
+}
+
+// replaceChunk stores packed as the chunk with the given ID.
+func replaceChunk(t *testing.T, u *Unchunker, chunkId string, packed []byte) {
+	t.Helper()
+	ctx := context.Background()
+	if err := u.Storage.Delete(ctx, chunkId); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Storage.Put(ctx, chunkId, packed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteChunk(t *testing.T) {
+	u, _ := newTestUnchunker(t)
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	plainId, err := u.Hasher.ComputeHash(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	putChunk(t, u, plainId, data, nil)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := u.WriteChunk(context.Background(), plainId, buf); err != nil {
+		t.Fatalf("WriteChunk(plain) = %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("WriteChunk(plain) wrote %q, want %q", buf.Bytes(), data)
+	}
+
+	u, _ = newTestUnchunker(t)
+	vc, _ := putVirtualChunk(t, u, data, 10)
+
+	buf.Reset()
+	header, err := u.WriteChunk(context.Background(), vc.ChunkId, buf)
+	if err != nil {
+		t.Fatalf("WriteChunk(virtual) = %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("WriteChunk(virtual) wrote %q, want %q", buf.Bytes(), data)
+	}
+	if header.Private.VirtualChunk == nil {
+		t.Errorf("WriteChunk(virtual) returned header %v", header)
+	}
+}
+
+func TestRejectsSubstitutedVirtualChunk(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		substitute func(t *testing.T, u *Unchunker, victim string, other []byte) []byte
+	}{
+		{"swapped", func(t *testing.T, u *Unchunker, victim string, other []byte) []byte {
+			return other
+		}},
+		{"renamed", func(t *testing.T, u *Unchunker, victim string, other []byte) []byte {
+			return chunktest.RenameChunk(t, u.Packer, other, victim)
+		}},
+	} {
+		u, _ := newTestUnchunker(t)
+		victim, _ := putVirtualChunk(t, u, []byte("the file that was asked for"), 10)
+		_, other := putVirtualChunk(t, u, []byte("some other file entirely"), 10)
+
+		replaceChunk(t, u, victim.ChunkId, tc.substitute(t, u, victim.ChunkId, other))
+		if _, err := u.WriteChunk(context.Background(), victim.ChunkId, ioutil.Discard); err == nil {
+			t.Errorf("%s: WriteChunk() succeeded", tc.name)
+		}
+	}
+}
+
+func TestRejectsBadSubchunk(t *testing.T) {
+	u, _ := newTestUnchunker(t)
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	vc, _ := putVirtualChunk(t, u, data, 10)
+
+	// References reordered under the same ID are only caught by hashing
+	// the reassembled content.
+	reordered := *vc
+	reordered.Chunk = append([]*pb.ChunkReference{vc.Chunk[1], vc.Chunk[0]}, vc.Chunk[2:]...)
+	replaceChunk(t, u, vc.ChunkId, putChunkBytes(t, u, &reordered))
+	if _, err := u.WriteChunk(context.Background(), vc.ChunkId, ioutil.Discard); err == nil {
+		t.Errorf("WriteChunk() with reordered references succeeded")
+	}
+
+	wrongLength := *vc
+	wrongLength.Chunk = append([]*pb.ChunkReference{{Hash: vc.Chunk[0].Hash, Length: 9}}, vc.Chunk[1:]...)
+	replaceChunk(t, u, vc.ChunkId, putChunkBytes(t, u, &wrongLength))
+	if _, err := u.WriteChunk(context.Background(), vc.ChunkId, ioutil.Discard); err == nil {
+		t.Errorf("WriteChunk() with a wrong subchunk length succeeded")
+	}
+}
+
+// putChunkBytes packs a virtual chunk without storing it.
+func putChunkBytes(t *testing.T, u *Unchunker, vc *pb.VirtualChunk) []byte {
+	t.Helper()
+	packed, err := u.Packer.Pack(nil, &deduchunk.ExtraData{VirtualChunk: vc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packed
+}
@@ -0,0 +1,104 @@
+package uploader
+
+import (
+	"fmt"
+
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/deduhash"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// manifestBuilder collects the references to the chunks of a file as they
+// are produced, and groups them into a tree of virtual chunks with at most
+// maxEntries references each.
+//
+// levels[0] holds references to data chunks not yet assigned to an
+// intermediate virtual chunk, levels[1] references to intermediate virtual
+// chunks of depth 1, and so on.
+type manifestBuilder struct {
+	hasher     *deduhash.Hasher
+	maxEntries int
+
+	levels        [][]*pb.ChunkReference
+	intermediates []*pb.VirtualChunk
+	numChunks     int
+}
+
+func newManifestBuilder(hasher *deduhash.Hasher, maxEntries int) *manifestBuilder {
+	if maxEntries <= 1 || maxEntries > deduchunk.MaxManifestEntries {
+		maxEntries = deduchunk.MaxManifestEntries
+	}
+	return &manifestBuilder{
+		hasher:     hasher,
+		maxEntries: maxEntries,
+	}
+}
+
+func (b *manifestBuilder) add(ref *pb.ChunkReference) error {
+	b.numChunks++
+	return b.addAt(0, ref)
+}
+
+func (b *manifestBuilder) addAt(level int, ref *pb.ChunkReference) error {
+	if level >= deduchunk.MaxManifestDepth {
+		return fmt.Errorf("Too many chunks for virtual chunks of depth at most %d with %d references each", deduchunk.MaxManifestDepth, b.maxEntries)
+	}
+	for len(b.levels) <= level {
+		b.levels = append(b.levels, nil)
+	}
+	if len(b.levels[level]) >= b.maxEntries {
+		if err := b.flush(level); err != nil {
+			return err
+		}
+	}
+	b.levels[level] = append(b.levels[level], ref)
+	return nil
+}
+
+// flush replaces the references at the given level with a reference, one
+// level up, to an intermediate virtual chunk holding them.
+func (b *manifestBuilder) flush(level int) error {
+	refs := b.levels[level]
+	b.levels[level] = nil
+
+	var length int64
+	for _, ref := range refs {
+		length += ref.Length
+	}
+
+	vc := &pb.VirtualChunk{
+		TotalLength:  length,
+		Chunk:        refs,
+		Depth:        int32(level + 1),
+		Intermediate: true,
+	}
+	chunkId, err := deduchunk.IntermediateChunkId(b.hasher, vc)
+	if err != nil {
+		return err
+	}
+	vc.ChunkId = chunkId
+	b.intermediates = append(b.intermediates, vc)
+
+	return b.addAt(level+1, &pb.ChunkReference{
+		Hash:    chunkId,
+		Length:  length,
+		Virtual: true,
+	})
+}
+
+// finish flushes all but the top level, and returns the references for the
+// root virtual chunk along with its depth.
+func (b *manifestBuilder) finish() ([]*pb.ChunkReference, int32, error) {
+	for level := 0; level < len(b.levels)-1; level++ {
+		if len(b.levels[level]) > 0 {
+			if err := b.flush(level); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	if len(b.levels) == 0 {
+		return nil, 1, nil
+	}
+	return b.levels[len(b.levels)-1], int32(len(b.levels)), nil
+}
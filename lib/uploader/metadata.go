@@ -0,0 +1,33 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+// fileMetadata describes the named file as it is about to be uploaded.
+// Timestamps are in RFC 3339 format, in UTC.
+func fileMetadata(filename string) (*pb.ChunkMetadata, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		logrus.Warnf("Unable to get hostname: %v", err)
+	}
+
+	return &pb.ChunkMetadata{
+		UploadTimestamp:       time.Now().UTC().Format(time.RFC3339Nano),
+		SuggestedFilename:     filepath.Base(filename),
+		ModificationTimestamp: info.ModTime().UTC().Format(time.RFC3339Nano),
+		Mode:                  uint32(info.Mode().Perm()),
+		Hostname:              hostname,
+	}, nil
+}
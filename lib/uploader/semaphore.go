@@ -0,0 +1,54 @@
+package uploader
+
+import (
+	"context"
+	"sync"
+)
+
+// byteSemaphore limits the number of bytes in flight. It is the
+// chunker.Budget of an upload.
+type byteSemaphore struct {
+	max int64
+
+	mu      sync.Mutex
+	cur     int64
+	changed chan struct{}
+}
+
+func newByteSemaphore(max int64) *byteSemaphore {
+	return &byteSemaphore{
+		max:     max,
+		changed: make(chan struct{}),
+	}
+}
+
+// Acquire blocks until n bytes are available or ctx is cancelled. A
+// request for more than the maximum is granted when nothing else is in
+// flight, so that a single large chunk cannot block forever.
+func (s *byteSemaphore) Acquire(ctx context.Context, n int64) error {
+	for {
+		s.mu.Lock()
+		if s.cur == 0 || s.cur+n <= s.max {
+			s.cur += n
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *byteSemaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,289 @@
+// Package uploader chunks, packs and uploads files, with the stages
+// running in parallel.
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/blobstore"
+	"github.com/steinarvk/dedu/lib/chunker"
+	"github.com/steinarvk/dedu/lib/deduchunk"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+const (
+	DefaultUploadWorkers    = 4
+	DefaultMaxInFlightBytes = 256 * 1024 * 1024
+)
+
+// Uploader uploads files as chunks. Chunks flow from the chunker to
+// PackWorkers packing goroutines (runtime.NumCPU() if zero) and on to
+// UploadWorkers uploading goroutines (DefaultUploadWorkers if zero).
+//
+// At most MaxInFlightBytes (DefaultMaxInFlightBytes if zero, and never
+// less than two chunks) of chunk plaintext is being read, packed or
+// uploaded at any time: the chunker does not read a chunk until there is
+// room for it, and the room is freed once the chunk has been uploaded.
+//
+// Files with more than MaxManifestEntries chunks (deduchunk.MaxManifestEntries
+// if zero) are described by a tree of virtual chunks, each referencing at
+// most that many chunks.
+type Uploader struct {
+	Chunker *chunker.Chunker
+	Packer  *deduchunk.Packer
+	Storage blobstore.BlobStore
+
+	PackWorkers      int
+	UploadWorkers    int
+	MaxInFlightBytes int64
+
+	MaxManifestEntries int
+
+	// OnChunk, if set, is called for each chunk once it has been stored
+	// or found to exist already. Calls are not concurrent.
+	OnChunk func(Result)
+
+	onChunkMu sync.Mutex
+}
+
+// Result describes a stored chunk.
+type Result struct {
+	ChunkId       string
+	Virtual       bool
+	AlreadyExists bool
+}
+
+type packJob struct {
+	chunk    chunker.Chunk
+	metadata *pb.ChunkMetadata
+}
+
+type packedChunk struct {
+	name   string
+	packed []byte
+	size   int64
+}
+
+func (u *Uploader) put(ctx context.Context, name string, packed []byte, virtual bool) error {
+	result := Result{ChunkId: name, Virtual: virtual}
+	if err := u.Storage.Put(ctx, name, packed); err != nil {
+		if err != blobstore.AlreadyExists {
+			return err
+		}
+		result.AlreadyExists = true
+	}
+
+	if u.OnChunk != nil {
+		u.onChunkMu.Lock()
+		defer u.onChunkMu.Unlock()
+		u.OnChunk(result)
+	}
+	return nil
+}
+
+// UploadFile uploads the named file and returns the ID of the chunk
+// representing all of it. If the file has more than one chunk, this is a
+// virtual chunk, which is uploaded after all the others (including any
+// intermediate virtual chunks).
+//
+// The chunk representing the whole file records its name, modification
+// time and permissions, along with the time of upload and the uploading
+// host. If that chunk already exists, the metadata it was stored with is
+// kept.
+func (u *Uploader) UploadFile(ctx context.Context, filename string) (string, error) {
+	packWorkers := u.PackWorkers
+	if packWorkers <= 0 {
+		packWorkers = runtime.NumCPU()
+	}
+	uploadWorkers := u.UploadWorkers
+	if uploadWorkers <= 0 {
+		uploadWorkers = DefaultUploadWorkers
+	}
+	maxInFlightBytes := u.MaxInFlightBytes
+	if maxInFlightBytes <= 0 {
+		maxInFlightBytes = DefaultMaxInFlightBytes
+	}
+	// The chunker holds one chunk back while it reads the next.
+	if min := 2 * u.Chunker.MaxChunkSize(); maxInFlightBytes < min {
+		maxInFlightBytes = min
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	metadata, err := fileMetadata(filename)
+	if err != nil {
+		return "", err
+	}
+
+	inFlight := newByteSemaphore(maxInFlightBytes)
+
+	packCh := make(chan packJob)
+	uploadCh := make(chan packedChunk)
+
+	packWG := sync.WaitGroup{}
+	for i := 0; i < packWorkers; i++ {
+		packWG.Add(1)
+		go func() {
+			defer packWG.Done()
+			for job := range packCh {
+				chunk := job.chunk
+				name := chunk.FinalHash
+				if chunk.Metadata != nil {
+					name = chunk.Metadata.HashOfPlaintext
+				}
+				size := int64(len(chunk.Plaintext))
+
+				var extra *deduchunk.ExtraData
+				if job.metadata != nil {
+					extra = &deduchunk.ExtraData{Metadata: job.metadata}
+				}
+
+				packed, err := u.Packer.Pack(chunk.Plaintext, extra)
+				chunk.Release()
+				if err != nil {
+					inFlight.Release(size)
+					fail(err)
+					continue
+				}
+
+				select {
+				case uploadCh <- packedChunk{name: name, packed: packed, size: size}:
+				case <-ctx.Done():
+					inFlight.Release(size)
+				}
+			}
+		}()
+	}
+	go func() {
+		packWG.Wait()
+		close(uploadCh)
+	}()
+
+	uploadWG := sync.WaitGroup{}
+	for i := 0; i < uploadWorkers; i++ {
+		uploadWG.Add(1)
+		go func() {
+			defer uploadWG.Done()
+			for pc := range uploadCh {
+				err := u.put(ctx, pc.name, pc.packed, false)
+				inFlight.Release(pc.size)
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	manifest := newManifestBuilder(u.Packer.Hasher, u.MaxManifestEntries)
+	var remoteBlob *pb.VirtualChunk
+
+	func() {
+		defer close(packCh)
+
+		for chunk := range u.Chunker.ReadFileWithBudget(ctx, filename, inFlight) {
+			if chunk.Error != nil {
+				fail(chunk.Error)
+				return
+			}
+			if chunk.Metadata != nil {
+				if chunk.Metadata.Chunk != nil {
+					if err := manifest.add(chunk.Metadata.Chunk); err != nil {
+						chunk.Release()
+						fail(err)
+						return
+					}
+				}
+			}
+			if chunk.Final {
+				remoteBlob = &pb.VirtualChunk{
+					ChunkId:         chunk.FinalHash,
+					TotalLength:     chunk.FinalLength,
+					PlaintextHashes: chunk.FinalHashes,
+				}
+			}
+
+			// A file of at most one chunk is represented by that chunk.
+			job := packJob{chunk: chunk}
+			if chunk.Final && manifest.numChunks <= 1 {
+				job.metadata = metadata
+			}
+
+			select {
+			case packCh <- job:
+			case <-ctx.Done():
+				inFlight.Release(int64(len(chunk.Plaintext)))
+				chunk.Release()
+				return
+			}
+		}
+	}()
+
+	uploadWG.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if remoteBlob == nil {
+		return "", fmt.Errorf("Sanity check failed: no final chunk for %q", filename)
+	}
+
+	logrus.Infof("Uploaded %d chunks of %q", manifest.numChunks, filename)
+
+	refs, depth, err := manifest.finish()
+	if err != nil {
+		return "", err
+	}
+	remoteBlob.Chunk = refs
+	if depth > 1 {
+		remoteBlob.Depth = depth
+	}
+
+	for _, vc := range manifest.intermediates {
+		packed, err := u.Packer.Pack(nil, &deduchunk.ExtraData{
+			VirtualChunk: vc,
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := u.put(ctx, vc.ChunkId, packed, true); err != nil {
+			return "", err
+		}
+	}
+	if len(manifest.intermediates) > 0 {
+		logrus.Infof("Uploaded %d intermediate virtual chunks of %q", len(manifest.intermediates), filename)
+	}
+
+	if len(remoteBlob.Chunk) > 1 || depth > 1 {
+		packed, err := u.Packer.Pack(nil, &deduchunk.ExtraData{
+			VirtualChunk: remoteBlob,
+			Metadata:     metadata,
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := u.put(ctx, remoteBlob.ChunkId, packed, true); err != nil {
+			return "", err
+		}
+	}
+
+	return remoteBlob.ChunkId, nil
+}
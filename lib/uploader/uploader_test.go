@@ -0,0 +1,222 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/steinarvk/dedu/lib/chunker"
+	"github.com/steinarvk/dedu/lib/deduchunk"
+	"github.com/steinarvk/dedu/lib/internal/chunktest"
+	"github.com/steinarvk/dedu/lib/unchunker"
+)
+
+const (
+	testChunkSize  = 100
+	testMaxEntries = 3
+)
+
+// testModTime is the modification time of uploaded test files.
+var testModTime = time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.UTC)
+
+type testEnv struct {
+	uploader  *Uploader
+	unchunker *unchunker.Unchunker
+	dir       string
+
+	mu      sync.Mutex
+	results []Result
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	packer := chunktest.NewPacker(t)
+	hasher := packer.Hasher
+	store := chunktest.NewStore(t)
+
+	env := &testEnv{dir: t.TempDir()}
+	env.uploader = &Uploader{
+		Chunker:            &chunker.Chunker{Hasher: hasher, ChunkSize: testChunkSize, Workers: 2},
+		Packer:             packer,
+		Storage:            store,
+		PackWorkers:        2,
+		UploadWorkers:      2,
+		MaxInFlightBytes:   4 * testChunkSize,
+		MaxManifestEntries: testMaxEntries,
+		OnChunk: func(r Result) {
+			env.mu.Lock()
+			defer env.mu.Unlock()
+			env.results = append(env.results, r)
+		},
+	}
+	env.unchunker = &unchunker.Unchunker{
+		Storage: store,
+		Packer:  packer,
+		Hasher:  hasher,
+	}
+	return env
+}
+
+func (env *testEnv) upload(t *testing.T, data []byte) (string, error) {
+	t.Helper()
+
+	filename := filepath.Join(env.dir, "file.bin")
+	if err := ioutil.WriteFile(filename, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, testModTime, testModTime); err != nil {
+		t.Fatal(err)
+	}
+
+	env.mu.Lock()
+	env.results = nil
+	env.mu.Unlock()
+
+	return env.uploader.UploadFile(context.Background(), filename)
+}
+
+func TestNestedRoundTrip(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		chunks        int
+		virtual       bool
+		depth         int32
+		intermediates int
+	}{
+		{chunks: 1},
+		{chunks: 3, virtual: true, depth: 1},
+		{chunks: 4, virtual: true, depth: 2, intermediates: 2},
+		{chunks: 9, virtual: true, depth: 2, intermediates: 3},
+		{chunks: 10, virtual: true, depth: 3, intermediates: 6},
+		{chunks: 27, virtual: true, depth: 3, intermediates: 12},
+		{chunks: 81, virtual: true, depth: 4, intermediates: 39},
+	} {
+		env := newTestEnv(t)
+		data := chunktest.Data(tc.chunks*testChunkSize - 17)
+
+		chunkId, err := env.upload(t, data)
+		if err != nil {
+			t.Fatalf("%d chunks: UploadFile() = %v", tc.chunks, err)
+		}
+		if want, _ := env.uploader.Packer.Hasher.ComputeHash(bytes.NewReader(data)); chunkId != want {
+			t.Errorf("%d chunks: UploadFile() = %q, want %q", tc.chunks, chunkId, want)
+		}
+
+		var virtual, plain int
+		for i, r := range env.results {
+			if r.AlreadyExists {
+				t.Errorf("%d chunks: chunk %q already existed", tc.chunks, r.ChunkId)
+			}
+			if r.Virtual {
+				virtual++
+			} else {
+				plain++
+			}
+			if r.ChunkId == chunkId && i != len(env.results)-1 {
+				t.Errorf("%d chunks: root uploaded before other chunks", tc.chunks)
+			}
+		}
+		wantVirtual := tc.intermediates
+		if tc.virtual {
+			wantVirtual++
+		}
+		if plain != tc.chunks || virtual != wantVirtual {
+			t.Errorf("%d chunks: uploaded %d data and %d virtual chunks, want %d and %d", tc.chunks, plain, virtual, tc.chunks, wantVirtual)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		header, err := env.unchunker.WriteChunk(context.Background(), chunkId, buf)
+		if err != nil {
+			t.Fatalf("%d chunks: WriteChunk() = %v", tc.chunks, err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%d chunks: WriteChunk() wrote %d bytes, want %d", tc.chunks, buf.Len(), len(data))
+		}
+
+		vc := header.Private.VirtualChunk
+		if (vc != nil) != tc.virtual {
+			t.Errorf("%d chunks: root has virtual chunk %v", tc.chunks, vc)
+		}
+		if vc != nil {
+			if got := deduchunk.ManifestDepth(vc); got != tc.depth {
+				t.Errorf("%d chunks: root has depth %d, want %d", tc.chunks, got, tc.depth)
+			}
+			if len(vc.Chunk) > testMaxEntries || vc.Intermediate {
+				t.Errorf("%d chunks: root has %d references (intermediate: %v)", tc.chunks, len(vc.Chunk), vc.Intermediate)
+			}
+		}
+		if md := header.Private.OptionalMetadata; md.GetSuggestedFilename() != "file.bin" || md.GetMode() != 0640 || md.GetHostname() != hostname {
+			t.Errorf("%d chunks: root has metadata %v", tc.chunks, md)
+		} else if mtime, err := time.Parse(time.RFC3339Nano, md.GetModificationTimestamp()); err != nil || !mtime.Equal(testModTime) {
+			t.Errorf("%d chunks: root has modification timestamp %q, want %v", tc.chunks, md.GetModificationTimestamp(), testModTime)
+		}
+	}
+}
+
+func TestReuploadFindsAllChunks(t *testing.T) {
+	env := newTestEnv(t)
+	data := chunktest.Data(10*testChunkSize + 1)
+
+	first, err := env.upload(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := env.upload(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("second upload returned %q, want %q", second, first)
+	}
+	for _, r := range env.results {
+		if !r.AlreadyExists {
+			t.Errorf("chunk %q uploaded again", r.ChunkId)
+		}
+	}
+}
+
+func TestRejectsTooManyChunks(t *testing.T) {
+	env := newTestEnv(t)
+
+	// Four levels of three references hold at most 81 chunks.
+	_, err := env.upload(t, chunktest.Data(81*testChunkSize+1))
+	if err == nil || !strings.Contains(err.Error(), "Too many chunks") {
+		t.Errorf("UploadFile() of 82 chunks = %v, want too many chunks", err)
+	}
+	for _, r := range env.results {
+		if r.Virtual {
+			t.Errorf("virtual chunk %q uploaded for a failed upload", r.ChunkId)
+		}
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	env := newTestEnv(t)
+
+	chunkId, err := env.upload(t, nil)
+	if err != nil {
+		t.Fatalf("UploadFile() = %v", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if _, err := env.unchunker.WriteChunk(context.Background(), chunkId, buf); err != nil || buf.Len() != 0 {
+		t.Errorf("WriteChunk() wrote %d bytes, %v", buf.Len(), err)
+	}
+}
+
+func TestMissingFile(t *testing.T) {
+	env := newTestEnv(t)
+	if _, err := env.uploader.UploadFile(context.Background(), filepath.Join(env.dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("UploadFile(missing) = %v, want not found", err)
+	}
+}
@@ -0,0 +1,342 @@
+// Package webdavstore stores blobs in a folder on a WebDAV server, as
+// offered by many NAS boxes.
+package webdavstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/steinarvk/dedu/lib/blobstore"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+const (
+	propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/><getcontentlength/><getlastmodified/></prop></propfind>`
+
+	maxErrorBodySize = 64 * 1024
+)
+
+var _ blobstore.BlobStore = &Storage{}
+
+type Storage struct {
+	creds   *pb.WebdavCredentials
+	baseURL *url.URL
+	folder  string
+	client  *http.Client
+}
+
+type httpError struct {
+	method string
+	status int
+	data   []byte
+}
+
+func (e httpError) Error() string {
+	return fmt.Sprintf("WebDAV %s failed: HTTP error %d", e.method, e.status)
+}
+
+type multistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string `xml:"DAV: status"`
+	Prop   struct {
+		ResourceType struct {
+			Collection *struct{} `xml:"DAV: collection"`
+		} `xml:"DAV: resourcetype"`
+		ContentLength string `xml:"DAV: getcontentlength"`
+		LastModified  string `xml:"DAV: getlastmodified"`
+	} `xml:"DAV: prop"`
+}
+
+func (r davResponse) blobInfo() (*blobstore.BlobInfo, bool, error) {
+	hrefPath := r.Href
+	if u, err := url.Parse(r.Href); err == nil {
+		hrefPath = u.Path
+	}
+	rv := &blobstore.BlobInfo{
+		Name: path.Base(strings.TrimSuffix(hrefPath, "/")),
+	}
+	isCollection := false
+
+	for _, ps := range r.Propstats {
+		if !strings.Contains(ps.Status, " 200 ") {
+			continue
+		}
+		if ps.Prop.ResourceType.Collection != nil {
+			isCollection = true
+		}
+		if ps.Prop.ContentLength != "" {
+			n, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("Bad content length %q for %q: %v", ps.Prop.ContentLength, r.Href, err)
+			}
+			rv.Size = n
+		}
+		if ps.Prop.LastModified != "" {
+			t, err := http.ParseTime(ps.Prop.LastModified)
+			if err != nil {
+				return nil, false, fmt.Errorf("Bad modification time %q for %q: %v", ps.Prop.LastModified, r.Href, err)
+			}
+			rv.Modified = t
+		}
+	}
+
+	return rv, isCollection, nil
+}
+
+func (s *Storage) url(components ...string) *url.URL {
+	u := *s.baseURL
+	u.Path = path.Join(append([]string{"/", u.Path}, components...)...)
+	u.RawPath = ""
+	return &u
+}
+
+func (s *Storage) blobURL(name string) *url.URL {
+	return s.url(s.folder, name)
+}
+
+func (s *Storage) do(ctx context.Context, method string, u *url.URL, body []byte, headers map[string]string) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if s.creds.Username != "" || s.creds.Password != "" {
+		req.SetBasicAuth(s.creds.Username, s.creds.Password)
+	}
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"method": method,
+		"host":   u.Host,
+		"path":   u.Path,
+	}).Debugf("Calling WebDAV")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("WebDAV %s %q failed: %v", method, u.Path, err)
+	}
+	return resp, nil
+}
+
+// doExpectOK performs a request and returns the response body, or an error
+// if the response status is not 2xx. 404 is mapped to os.ErrNotExist.
+func (s *Storage) doExpectOK(ctx context.Context, method string, u *url.URL, body []byte, headers map[string]string) ([]byte, error) {
+	resp, err := s.do(ctx, method, u, body, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, httpError{method: method, status: resp.StatusCode, data: data}
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("WebDAV %s %q failed: Read error: %v", method, u.Path, err)
+	}
+	return data, nil
+}
+
+func (s *Storage) propfind(ctx context.Context, u *url.URL, depth string) ([]davResponse, error) {
+	headers := map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	}
+	data, err := s.doExpectOK(ctx, "PROPFIND", u, []byte(propfindBody), headers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := multistatus{}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("WebDAV PROPFIND %q failed: XML decode error: %v", u.Path, err)
+	}
+	return result.Responses, nil
+}
+
+func (s *Storage) Put(ctx context.Context, name string, data []byte) error {
+	if _, err := s.Stat(ctx, name); err == nil {
+		return blobstore.AlreadyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	t0 := time.Now()
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if _, err := s.doExpectOK(ctx, "PUT", s.blobURL(name), data, headers); err != nil {
+		return err
+	}
+	dur := time.Since(t0)
+	speed := float64(len(data)) / dur.Seconds()
+	logrus.WithFields(logrus.Fields{
+		"size":     len(data),
+		"duration": dur,
+		"speed":    speed,
+	}).Infof("Uploaded file %q", name)
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, name string) ([]byte, error) {
+	t0 := time.Now()
+	data, err := s.doExpectOK(ctx, "GET", s.blobURL(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	dur := time.Since(t0)
+	logrus.WithFields(logrus.Fields{
+		"filename": name,
+		"size":     len(data),
+		"speed":    float64(len(data)) / dur.Seconds(),
+		"duration": dur,
+	}).Infof("Download from WebDAV successful")
+	return data, nil
+}
+
+func (s *Storage) Stat(ctx context.Context, name string) (*blobstore.BlobInfo, error) {
+	responses, err := s.propfind(ctx, s.blobURL(name), "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) != 1 {
+		return nil, fmt.Errorf("WebDAV PROPFIND for %q returned %d responses, expected 1", name, len(responses))
+	}
+
+	info, isCollection, err := responses[0].blobInfo()
+	if err != nil {
+		return nil, err
+	}
+	if isCollection {
+		return nil, fmt.Errorf("%q is a collection", name)
+	}
+	info.Name = name
+	return info, nil
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]blobstore.BlobInfo, error) {
+	u := s.url(s.folder)
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	responses, err := s.propfind(ctx, u, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []blobstore.BlobInfo
+	for _, resp := range responses {
+		info, isCollection, err := resp.blobInfo()
+		if err != nil {
+			return nil, err
+		}
+		if isCollection || !strings.HasPrefix(info.Name, prefix) {
+			continue
+		}
+		rv = append(rv, *info)
+	}
+	return rv, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, name string) error {
+	if _, err := s.doExpectOK(ctx, "DELETE", s.blobURL(name), nil, nil); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"filename": name,
+	}).Infof("Deleted file %q", name)
+	return nil
+}
+
+func (s *Storage) ensureFolderExists(ctx context.Context) error {
+	var components []string
+	for _, component := range strings.Split(s.folder, "/") {
+		if component == "" {
+			continue
+		}
+		components = append(components, component)
+
+		u := s.url(components...)
+		u.Path += "/"
+
+		resp, err := s.do(ctx, "MKCOL", u, nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusCreated:
+			logrus.Infof("Created WebDAV collection %q", u.Path)
+		case http.StatusMethodNotAllowed:
+			// Already exists.
+		default:
+			return httpError{method: "MKCOL", status: resp.StatusCode}
+		}
+	}
+	return nil
+}
+
+// New connects to the given storage. If httpClient is nil, a default
+// client is used.
+func New(ctx context.Context, creds *pb.WebdavCredentials, folder string, httpClient *http.Client) (*Storage, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	baseURL, err := url.Parse(creds.BaseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid WebDAV base URL %q: %v", creds.BaseUrl, err)
+	}
+	if baseURL.Scheme != "https" && baseURL.Scheme != "http" {
+		return nil, fmt.Errorf("Invalid WebDAV base URL %q: scheme must be http or https", creds.BaseUrl)
+	}
+
+	store := &Storage{
+		creds:   creds,
+		baseURL: baseURL,
+		folder:  strings.Trim(folder, "/"),
+		client:  httpClient,
+	}
+
+	if err := store.ensureFolderExists(ctx); err != nil {
+		return nil, fmt.Errorf("Failed to ensure that WebDAV folder %q exists: %v", folder, err)
+	}
+
+	if _, err := store.propfind(ctx, store.url(store.folder), "0"); err != nil {
+		return nil, fmt.Errorf("Unable to access WebDAV folder %q at %q: %v", folder, creds.BaseUrl, err)
+	}
+
+	return store, nil
+}
@@ -0,0 +1,307 @@
+package webdavstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/steinarvk/dedu/lib/blobstore"
+
+	pb "github.com/steinarvk/dedu/gen/dedupb"
+)
+
+const (
+	testUsername = "user"
+	testPassword = "secret"
+)
+
+// testServer is an in-process WebDAV server mounted under /dav, which
+// records the requests it receives.
+type testServer struct {
+	fs      webdav.FileSystem
+	handler *webdav.Handler
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newTestServer(t *testing.T) (*testServer, *httptest.Server) {
+	fs := webdav.NewMemFS()
+	s := &testServer{
+		fs: fs,
+		handler: &webdav.Handler{
+			Prefix:     "/dav",
+			FileSystem: fs,
+			LockSystem: webdav.NewMemLS(),
+		},
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != testUsername || password != testPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *testServer) countRequests(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if strings.HasPrefix(r, method+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func testCreds(server *httptest.Server) *pb.WebdavCredentials {
+	return &pb.WebdavCredentials{
+		BaseUrl:  server.URL + "/dav",
+		Username: testUsername,
+		Password: testPassword,
+	}
+}
+
+func TestNewCreatesNestedFolder(t *testing.T) {
+	ctx := context.Background()
+	dav, server := newTestServer(t)
+
+	if _, err := New(ctx, testCreds(server), "/a/b/c/", nil); err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	info, err := dav.fs.Stat(ctx, "/a/b/c")
+	if err != nil || !info.IsDir() {
+		t.Fatalf("folder not created: %v, %v", info, err)
+	}
+	if n := dav.countRequests("MKCOL"); n != 3 {
+		t.Errorf("New() made %d MKCOL requests, want 3", n)
+	}
+
+	// Existing collections are not an error.
+	if _, err := New(ctx, testCreds(server), "a/b/c/d", nil); err != nil {
+		t.Fatalf("New() with existing parents = %v", err)
+	}
+	if _, err := dav.fs.Stat(ctx, "/a/b/c/d"); err != nil {
+		t.Errorf("folder not created: %v", err)
+	}
+}
+
+func TestNewRejectsBadCredentials(t *testing.T) {
+	_, server := newTestServer(t)
+
+	creds := testCreds(server)
+	creds.Password = "wrong"
+	if _, err := New(context.Background(), creds, "blobs", nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("New() with wrong password = %v, want HTTP error 401", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dav, server := newTestServer(t)
+
+	s, err := New(ctx, testCreds(server), "blobs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello, world")
+	if err := s.Put(ctx, "1-abc", data); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if _, err := dav.fs.Stat(ctx, "/blobs/1-abc"); err != nil {
+		t.Errorf("blob not stored in folder: %v", err)
+	}
+	if err := s.Put(ctx, "1-abc", []byte("other data")); err != blobstore.AlreadyExists {
+		t.Errorf("second Put() = %v, want AlreadyExists", err)
+	}
+	if n := dav.countRequests("PUT"); n != 1 {
+		t.Errorf("made %d PUT requests, want 1", n)
+	}
+
+	got, err := s.Get(ctx, "1-abc")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Get() = %q, %v; want %q", got, err, data)
+	}
+
+	info, err := s.Stat(ctx, "1-abc")
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	if info.Name != "1-abc" || info.Size != int64(len(data)) || info.Modified.IsZero() {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get(missing) = %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat(missing) = %v, want os.ErrNotExist", err)
+	}
+
+	if err := s.Delete(ctx, "1-abc"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if _, err := s.Stat(ctx, "1-abc"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat(deleted) = %v, want os.ErrNotExist", err)
+	}
+	if err := s.Delete(ctx, "1-abc"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Delete(deleted) = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestStatRejectsCollection(t *testing.T) {
+	ctx := context.Background()
+	dav, server := newTestServer(t)
+
+	s, err := New(ctx, testCreds(server), "blobs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dav.fs.Mkdir(ctx, "/blobs/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat(ctx, "sub"); err == nil {
+		t.Errorf("Stat(collection) succeeded")
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	dav, server := newTestServer(t)
+
+	s, err := New(ctx, testCreds(server), "blobs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"1-a", "1-bb", "1-ccc", "2-a"}
+	for _, name := range names {
+		if err := s.Put(ctx, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dav.fs.Mkdir(ctx, "/blobs/1-sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := dav.fs.OpenFile(ctx, "/blobs/1-sub/1-nested", os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	infos, err := s.List(ctx, "1-")
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	var got []string
+	for _, info := range infos {
+		got = append(got, info.Name)
+		if info.Size != int64(len(info.Name)) || info.Modified.IsZero() {
+			t.Errorf("List() entry %+v has wrong size or time", info)
+		}
+	}
+	sort.Strings(got)
+	if want := names[:3]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	all, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(all) != len(names) {
+		t.Errorf("List(\"\") returned %d entries, want %d: %v", len(all), len(names), all)
+	}
+}
+
+func TestBlobInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		response     davResponse
+		want         string
+		wantSize     int64
+		isCollection bool
+		wantErr      bool
+	}{
+		{
+			name:     "absolute URL",
+			response: davResponse{Href: "https://nas.example.com/dav/blobs/1-a%20b", Propstats: []davPropstat{okPropstat("12", "")}},
+			want:     "1-a b",
+			wantSize: 12,
+		},
+		{
+			name:         "collection",
+			response:     davResponse{Href: "/dav/blobs/", Propstats: []davPropstat{collectionPropstat()}},
+			want:         "blobs",
+			isCollection: true,
+		},
+		{
+			name: "failed propstat ignored",
+			response: davResponse{Href: "/dav/blobs/x", Propstats: []davPropstat{
+				okPropstat("3", "Mon, 02 Jan 2006 15:04:05 GMT"),
+				{Status: "HTTP/1.1 404 Not Found"},
+			}},
+			want:     "x",
+			wantSize: 3,
+		},
+		{
+			name:     "bad length",
+			response: davResponse{Href: "/dav/blobs/x", Propstats: []davPropstat{okPropstat("many", "")}},
+			wantErr:  true,
+		},
+		{
+			name:     "bad time",
+			response: davResponse{Href: "/dav/blobs/x", Propstats: []davPropstat{okPropstat("3", "yesterday")}},
+			wantErr:  true,
+		},
+	} {
+		info, isCollection, err := tc.response.blobInfo()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: blobInfo() succeeded", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: blobInfo() = %v", tc.name, err)
+			continue
+		}
+		if info.Name != tc.want || info.Size != tc.wantSize || isCollection != tc.isCollection {
+			t.Errorf("%s: blobInfo() = %+v, %v", tc.name, info, isCollection)
+		}
+	}
+}
+
+func okPropstat(length, modified string) davPropstat {
+	ps := davPropstat{Status: "HTTP/1.1 200 OK"}
+	ps.Prop.ContentLength = length
+	ps.Prop.LastModified = modified
+	return ps
+}
+
+func collectionPropstat() davPropstat {
+	ps := davPropstat{Status: "HTTP/1.1 200 OK"}
+	ps.Prop.ResourceType.Collection = &struct{}{}
+	return ps
+}
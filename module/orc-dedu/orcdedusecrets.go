@@ -44,7 +44,6 @@ func findConfigDir() (string, bool, error) {
 			expanded, err := homedir.Expand(path)
 			if err != nil {
 				return "", false, fmt.Errorf("Failed to expand homedir in %q: %v", path, err)
-				continue
 			}
 			path = expanded
 		}
@@ -119,6 +118,15 @@ func (m *Module) OnRegister(hooks orc.ModuleHooks) {
 		}
 		logrus.Infof("Hash of empty blob: %q", emptyHash)
 		if h := dedu.Config.EmptyBlobHashSanityCheck; h != "" {
+			// The check may predate a change of hash version.
+			hasher, err := dedu.Hasher.ForHash(h)
+			if err != nil {
+				return fmt.Errorf("Config error: bad empty_blob_hash_sanity_check %q: %v", h, err)
+			}
+			emptyHash, err := hasher.ComputeHash(strings.NewReader(""))
+			if err != nil {
+				return err
+			}
 			if h != emptyHash {
 				return fmt.Errorf("Config mismatch: expected %q to be hash of empty blob, but got %q", h, emptyHash)
 			}